@@ -0,0 +1,53 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+
+	auditActor = "odh-model-controller"
+)
+
+// auditSuppressed disables the audit records when the changes of the controller are not
+// applied, see NewDryRunClient
+var auditSuppressed bool
+
+// auditLog records a change made by the controller to a resource governing the access to
+// the models (routes, ingresses, role bindings and mesh membership), so that the changes
+// of the model exposure can be tracked. The records are written to the dedicated "audit"
+// logger with a fixed set of keys
+func auditLog(action string, kind string, obj client.Object, reason string) {
+	if auditSuppressed {
+		return
+	}
+	ctrl.Log.WithName("audit").Info("Access policy changed",
+		"actor", auditActor,
+		"action", action,
+		"kind", kind,
+		"name", obj.GetName(),
+		"namespace", obj.GetNamespace(),
+		"reason", reason,
+		"timestamp", time.Now().UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,116 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// subReconcilerFailureThreshold is the number of consecutive failures of a sub-reconciler
+	// after which it is skipped until its backoff expires
+	subReconcilerFailureThreshold = 3
+	// subReconcilerBaseBackoff and subReconcilerMaxBackoff bound the exponential backoff of
+	// the failing sub-reconcilers
+	subReconcilerBaseBackoff = 5 * time.Second
+	subReconcilerMaxBackoff  = 10 * time.Minute
+)
+
+// subReconcilerState tracks the consecutive failures of a sub-reconciler
+type subReconcilerState struct {
+	failures  int
+	openUntil time.Time
+}
+
+// circuitBreaker keeps a persistently failing sub-reconciler (e.g. one whose API is
+// missing) from requeuing its InferenceService endlessly: once the error budget of the
+// sub-reconciler is exhausted, its circuit opens and it is skipped for an exponentially
+// growing backoff, while the other sub-reconcilers proceed
+type circuitBreaker struct {
+	mu     sync.Mutex
+	states map[types.NamespacedName]map[string]*subReconcilerState
+	now    func() time.Time
+}
+
+func newCircuitBreaker() *circuitBreaker {
+	return &circuitBreaker{
+		states: map[types.NamespacedName]map[string]*subReconcilerState{},
+		now:    time.Now,
+	}
+}
+
+// allow returns true if the sub-reconciler can run for the InferenceService, otherwise the
+// time left before its circuit closes
+func (b *circuitBreaker) allow(key types.NamespacedName, name string) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state, ok := b.states[key][name]
+	if !ok {
+		return true, 0
+	}
+	if remaining := state.openUntil.Sub(b.now()); remaining > 0 {
+		return false, remaining
+	}
+	return true, 0
+}
+
+// success resets the error budget of the sub-reconciler
+func (b *circuitBreaker) success(key types.NamespacedName, name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.states[key], name)
+	if len(b.states[key]) == 0 {
+		delete(b.states, key)
+	}
+}
+
+// failure records a failure of the sub-reconciler. It returns the backoff of the
+// sub-reconciler when its circuit opens, zero while its error budget is not exhausted
+func (b *circuitBreaker) failure(key types.NamespacedName, name string) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.states[key] == nil {
+		b.states[key] = map[string]*subReconcilerState{}
+	}
+	state, ok := b.states[key][name]
+	if !ok {
+		state = &subReconcilerState{}
+		b.states[key][name] = state
+	}
+	state.failures++
+	if state.failures < subReconcilerFailureThreshold {
+		return 0
+	}
+	backoff := subReconcilerBaseBackoff
+	for i := subReconcilerFailureThreshold; i < state.failures && backoff < subReconcilerMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > subReconcilerMaxBackoff {
+		backoff = subReconcilerMaxBackoff
+	}
+	state.openUntil = b.now().Add(backoff)
+	return backoff
+}
+
+// forget drops the state of the sub-reconcilers of a deleted InferenceService
+func (b *circuitBreaker) forget(key types.NamespacedName) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.states, key)
+}
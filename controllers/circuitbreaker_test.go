@@ -0,0 +1,88 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("The sub-reconciler circuit breaker", func() {
+	key := types.NamespacedName{Name: "example-onnx-mnist", Namespace: WorkingNamespace}
+	var breaker *circuitBreaker
+	var now time.Time
+
+	BeforeEach(func() {
+		now = time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+		breaker = newCircuitBreaker()
+		breaker.now = func() time.Time { return now }
+	})
+
+	It("Should open the circuit once the error budget is exhausted", func() {
+		for i := 1; i < subReconcilerFailureThreshold; i++ {
+			Expect(breaker.failure(key, "route")).To(BeZero())
+			allowed, _ := breaker.allow(key, "route")
+			Expect(allowed).To(BeTrue())
+		}
+
+		Expect(breaker.failure(key, "route")).To(Equal(subReconcilerBaseBackoff))
+		allowed, remaining := breaker.allow(key, "route")
+		Expect(allowed).To(BeFalse())
+		Expect(remaining).To(Equal(subReconcilerBaseBackoff))
+
+		By("By checking that the other sub-reconcilers still run")
+
+		allowed, _ = breaker.allow(key, "monitoring")
+		Expect(allowed).To(BeTrue())
+
+		By("By checking that the circuit closes once the backoff expires")
+
+		now = now.Add(subReconcilerBaseBackoff)
+		allowed, _ = breaker.allow(key, "route")
+		Expect(allowed).To(BeTrue())
+	})
+
+	It("Should double the backoff up to its maximum", func() {
+		for i := 1; i < subReconcilerFailureThreshold; i++ {
+			breaker.failure(key, "route")
+		}
+		backoffs := []time.Duration{}
+		for i := 0; i < 9; i++ {
+			backoffs = append(backoffs, breaker.failure(key, "route"))
+		}
+		Expect(backoffs).To(Equal([]time.Duration{
+			5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second, 80 * time.Second,
+			160 * time.Second, 320 * time.Second, subReconcilerMaxBackoff, subReconcilerMaxBackoff,
+		}))
+	})
+
+	It("Should reset the error budget on success", func() {
+		for i := 0; i < subReconcilerFailureThreshold; i++ {
+			breaker.failure(key, "route")
+		}
+		breaker.success(key, "route")
+		allowed, _ := breaker.allow(key, "route")
+		Expect(allowed).To(BeTrue())
+		Expect(breaker.failure(key, "route")).To(BeZero())
+		Expect(breaker.states).To(HaveKey(key))
+
+		breaker.forget(key)
+		Expect(breaker.states).NotTo(HaveKey(key))
+	})
+})
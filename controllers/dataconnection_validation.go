@@ -0,0 +1,190 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// connectionTestAnnotation requests a test of the data connection, its value (e.g. a
+	// timestamp) identifies the request so that the test is run once per value
+	connectionTestAnnotation = "opendatahub.io/connection-test"
+	// connectionTestIDAnnotation, connectionTestResultAnnotation and
+	// connectionTestMessageAnnotation report the result of the last test
+	connectionTestIDAnnotation      = "opendatahub.io/connection-test-id"
+	connectionTestResultAnnotation  = "opendatahub.io/connection-test-result"
+	connectionTestMessageAnnotation = "opendatahub.io/connection-test-message"
+	connectionTestSucceeded         = "succeeded"
+	connectionTestFailed            = "failed"
+	// connectionTestTimeout bounds the duration of the request to the S3 endpoint
+	connectionTestTimeout = 10 * time.Second
+	// emptyPayloadHash is the SHA-256 of the empty body of the HEAD request
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// connectionTestRequested returns true if the data connection has a test request not yet run
+func connectionTestRequested(secret *corev1.Secret) bool {
+	id := secret.Annotations[connectionTestAnnotation]
+	return id != "" && id != secret.Annotations[connectionTestIDAnnotation]
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of the data with the key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// signS3Request signs the request with the AWS Signature Version 4 of the S3 service
+func signS3Request(req *http.Request, accessKeyID string, secretAccessKey string, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", emptyPayloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" + "x-amz-content-sha256:" + emptyPayloadHash + "\n" + "x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalRequestHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// testDataConnection checks that the credentials of the data connection grant access to its
+// bucket with a HEAD Bucket request. It returns the error explaining the failure
+func testDataConnection(ctx context.Context, secret *corev1.Secret) error {
+	endpoint := strings.TrimSpace(string(secret.Data["AWS_S3_ENDPOINT"]))
+	bucket := strings.TrimSpace(string(secret.Data["AWS_S3_BUCKET"]))
+	region := strings.TrimSpace(string(secret.Data["AWS_DEFAULT_REGION"]))
+	if endpoint == "" || bucket == "" {
+		return fmt.Errorf("the endpoint and the bucket of the data connection are required")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil || endpointURL.Host == "" {
+		return fmt.Errorf("invalid endpoint %q", endpoint)
+	}
+	endpointURL.Path = strings.TrimRight(endpointURL.Path, "/") + "/" + bucket
+
+	tlsConfig := &tls.Config{}
+	if verifySSL, ok := secret.Data[dataConnectionVerifySSLKey]; ok && verifySSLValue(string(verifySSL)) == "0" {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	if caBundle := secret.Data[dataConnectionCABundleKey]; len(caBundle) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBundle) {
+			return fmt.Errorf("invalid CA bundle")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	httpClient := &http.Client{
+		Timeout:   connectionTestTimeout,
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig},
+		// A redirection means that the bucket is in another region
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, endpointURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	signS3Request(req, string(secret.Data["AWS_ACCESS_KEY_ID"]), string(secret.Data["AWS_SECRET_ACCESS_KEY"]),
+		region, time.Now())
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to reach the endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusForbidden:
+		return fmt.Errorf("access denied to the bucket %s, check the credentials", bucket)
+	case http.StatusNotFound:
+		return fmt.Errorf("the bucket %s does not exist", bucket)
+	case http.StatusMovedPermanently, http.StatusBadRequest:
+		if bucketRegion := resp.Header.Get("x-amz-bucket-region"); bucketRegion != "" && bucketRegion != region {
+			return fmt.Errorf("the bucket %s is in the region %s, not %s", bucket, bucketRegion, region)
+		}
+	}
+	return fmt.Errorf("unexpected response from the endpoint: %s", resp.Status)
+}
+
+// reconcileConnectionTest tests the data connection when requested and writes the result
+// in its annotations
+func (r *StorageSecretReconciler) reconcileConnectionTest(ctx context.Context, secret *corev1.Secret) error {
+	if !connectionTestRequested(secret) {
+		return nil
+	}
+	// Initialize logger format
+	log := r.Log.WithValues("secret", secret.Name, "namespace", secret.Namespace)
+
+	id := secret.Annotations[connectionTestAnnotation]
+	result, message := connectionTestSucceeded, "The credentials grant access to the bucket"
+	if err := testDataConnection(ctx, secret); err != nil {
+		result, message = connectionTestFailed, err.Error()
+	}
+	log.Info("Data connection tested", "result", result, "message", message)
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// Get the last data connection revision
+		if err := r.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, secret); err != nil {
+			return err
+		}
+		if secret.Annotations == nil {
+			secret.Annotations = map[string]string{}
+		}
+		secret.Annotations[connectionTestIDAnnotation] = id
+		secret.Annotations[connectionTestResultAnnotation] = result
+		secret.Annotations[connectionTestMessageAnnotation] = message
+		return r.Update(ctx, secret)
+	})
+}
@@ -0,0 +1,231 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Dependency is an API served by another operator that the controller relies on
+type Dependency struct {
+	GroupVersion string
+	Kind         string
+	// The controller reports itself as not ready when a required dependency is missing,
+	// while it keeps running with reduced capabilities when an optional one is missing
+	Required bool
+}
+
+var (
+	InferenceServiceDependency   = Dependency{GroupVersion: "serving.kserve.io/v1beta1", Kind: "InferenceService", Required: true}
+	ServingRuntimeDependency     = Dependency{GroupVersion: "serving.kserve.io/v1alpha1", Kind: "ServingRuntime", Required: true}
+	RouteDependency              = Dependency{GroupVersion: "route.openshift.io/v1", Kind: "Route"}
+	ServiceMonitorDependency     = Dependency{GroupVersion: "monitoring.coreos.com/v1", Kind: "ServiceMonitor"}
+	PrometheusRuleDependency     = Dependency{GroupVersion: "monitoring.coreos.com/v1", Kind: "PrometheusRule"}
+	AcceleratorProfileDependency = Dependency{GroupVersion: "dashboard.opendatahub.io/v1", Kind: "AcceleratorProfile"}
+	ServiceMeshMemberDependency  = Dependency{GroupVersion: "maistra.io/v1", Kind: "ServiceMeshMember"}
+	TemplateDependency           = Dependency{GroupVersion: "template.openshift.io/v1", Kind: "Template"}
+	DSCInitializationDependency  = Dependency{GroupVersion: "dscinitialization.opendatahub.io/v1", Kind: "DSCInitialization"}
+	ProxyDependency              = Dependency{GroupVersion: "config.openshift.io/v1", Kind: "Proxy"}
+
+	// Dependencies lists all the APIs checked by the DependencyChecker
+	Dependencies = []Dependency{
+		InferenceServiceDependency,
+		ServingRuntimeDependency,
+		RouteDependency,
+		ServiceMonitorDependency,
+		PrometheusRuleDependency,
+		AcceleratorProfileDependency,
+		ServiceMeshMemberDependency,
+		TemplateDependency,
+		DSCInitializationDependency,
+		ProxyDependency,
+	}
+
+	dependencyAvailable = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "odh_model_controller_dependency_available",
+		Help: "Whether an API the controller depends on is served by the cluster (1) or not (0)",
+	}, []string{"group_version", "kind"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(dependencyAvailable)
+}
+
+// DependencyChecker reports the availability of the APIs the controller depends on
+type DependencyChecker struct {
+	Discovery discovery.DiscoveryInterface
+	Log       logr.Logger
+	// MaxAge is how long the result of the last discovery is reused by the readiness
+	// check, so that the probes do not query the API server every time
+	MaxAge time.Duration
+
+	mutex     sync.Mutex
+	checked   time.Time
+	checkErr  error
+	available map[Dependency]bool
+}
+
+// Available returns true if the API of the dependency is served by the cluster
+func (c *DependencyChecker) Available(dependency Dependency) (bool, error) {
+	resources, err := c.Discovery.ServerResourcesForGroupVersion(dependency.GroupVersion)
+	if err != nil {
+		if apierrs.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Kind == dependency.Kind {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// discover returns the availability of all the dependencies, and exports it as a gauge so
+// the degraded mode of the controller can be observed
+func (c *DependencyChecker) discover() (map[Dependency]bool, error) {
+	available := map[Dependency]bool{}
+	for _, dependency := range Dependencies {
+		served, err := c.Available(dependency)
+		if err != nil {
+			c.Log.Error(err, "Unable to discover the API", "groupVersion", dependency.GroupVersion, "kind", dependency.Kind)
+			return nil, err
+		}
+		available[dependency] = served
+		gauge := dependencyAvailable.WithLabelValues(dependency.GroupVersion, dependency.Kind)
+		if served {
+			gauge.Set(1)
+		} else {
+			gauge.Set(0)
+		}
+	}
+	return available, nil
+}
+
+// Refresh discovers all the dependencies and caches the result for the readiness check.
+// The availability of the last successful discovery is kept when the API server is
+// unreachable
+func (c *DependencyChecker) Refresh() error {
+	available, err := c.discover()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.checked = time.Now()
+	c.checkErr = err
+	if err == nil {
+		c.available = available
+	}
+	return err
+}
+
+// cachedAvailable returns true if the dependency was served at the last successful discovery
+func (c *DependencyChecker) cachedAvailable(dependency Dependency) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.available[dependency]
+}
+
+// Check implements a healthz.Checker failing when the API server is unreachable or a
+// required dependency is missing. It reads the cached result of the last discovery, which
+// is refreshed by the DependencyWatcher, or here once older than MaxAge
+func (c *DependencyChecker) Check(_ *http.Request) error {
+	c.mutex.Lock()
+	stale := c.checked.IsZero() || time.Since(c.checked) > c.MaxAge
+	c.mutex.Unlock()
+	if stale {
+		// The error is cached with the result
+		_ = c.Refresh()
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.checkErr != nil {
+		return c.checkErr
+	}
+	var missing []string
+	for _, dependency := range Dependencies {
+		if dependency.Required && !c.available[dependency] {
+			missing = append(missing, dependency.Kind+"."+dependency.GroupVersion)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("required APIs are not installed: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// DependencyWatcher polls the cluster for the dependencies, refreshing the cached result of
+// the readiness check. Once one of the dependencies missing when the controllers were set
+// up is served, e.g. a CRD installed afterwards by OLM or Helm, it stops the manager so
+// that the controllers are set up again with the features relying on it, without
+// restarting the controller process
+type DependencyWatcher struct {
+	Checker *DependencyChecker
+	// Missing are the dependencies that were not served when the controllers were set up
+	Missing  []Dependency
+	Interval time.Duration
+	// Stop stops the manager running the controllers
+	Stop      context.CancelFunc
+	installed int32
+}
+
+// Start polls the dependencies until a missing one is installed or the context is done
+func (w *DependencyWatcher) Start(ctx context.Context) error {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		// The error is logged by the checker and reported by the readiness check
+		if err := w.Checker.Refresh(); err != nil {
+			continue
+		}
+		for _, dependency := range w.Missing {
+			if w.Checker.cachedAvailable(dependency) {
+				w.Checker.Log.Info("API installed, setting up the controllers again to enable the features relying on it",
+					"groupVersion", dependency.GroupVersion, "kind", dependency.Kind)
+				atomic.StoreInt32(&w.installed, 1)
+				w.Stop()
+				return nil
+			}
+		}
+	}
+}
+
+// NeedLeaderElection returns false, all the replicas set up their controllers again
+func (w *DependencyWatcher) NeedLeaderElection() bool {
+	return false
+}
+
+// Installed returns true if the manager was stopped because a missing dependency was installed
+func (w *DependencyWatcher) Installed() bool {
+	return atomic.LoadInt32(&w.installed) == 1
+}
@@ -0,0 +1,74 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("The dependency checker", func() {
+	var fakeDiscovery *fakediscovery.FakeDiscovery
+	var checker *DependencyChecker
+
+	BeforeEach(func() {
+		fakeDiscovery = &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+		fakeDiscovery.Resources = []*metav1.APIResourceList{{
+			GroupVersion: InferenceServiceDependency.GroupVersion,
+			APIResources: []metav1.APIResource{{Name: "inferenceservices", Kind: InferenceServiceDependency.Kind}},
+		}}
+		checker = &DependencyChecker{
+			Discovery: fakeDiscovery,
+			Log:       ctrl.Log.WithName("dependencies"),
+			MaxAge:    time.Hour,
+		}
+	})
+
+	It("Should report the readiness from the last discovery", func() {
+		Expect(checker.Check(nil)).To(MatchError(ContainSubstring("ServingRuntime.serving.kserve.io/v1alpha1")))
+		discoveries := len(fakeDiscovery.Actions())
+		Expect(discoveries).To(Equal(len(Dependencies)))
+
+		By("By checking that the probes do not discover the APIs again")
+
+		fakeDiscovery.Resources = append(fakeDiscovery.Resources, &metav1.APIResourceList{
+			GroupVersion: ServingRuntimeDependency.GroupVersion,
+			APIResources: []metav1.APIResource{{Name: "servingruntimes", Kind: ServingRuntimeDependency.Kind}},
+		})
+		Expect(checker.Check(nil)).To(HaveOccurred())
+		Expect(fakeDiscovery.Actions()).To(HaveLen(discoveries))
+
+		By("By checking that the refreshed discovery is reported")
+
+		Expect(checker.Refresh()).To(Succeed())
+		Expect(checker.Check(nil)).To(Succeed())
+		Expect(checker.cachedAvailable(ServingRuntimeDependency)).To(BeTrue())
+		Expect(checker.cachedAvailable(RouteDependency)).To(BeFalse())
+	})
+
+	It("Should discover the APIs again once the result is older than the maximum age", func() {
+		checker.MaxAge = 0
+		Expect(checker.Check(nil)).To(HaveOccurred())
+		Expect(checker.Check(nil)).To(HaveOccurred())
+		Expect(fakeDiscovery.Actions()).To(HaveLen(2 * len(Dependencies)))
+	})
+})
@@ -0,0 +1,159 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// redactedValue replaces the sensitive values of the logged or exported resources
+const redactedValue = "<redacted>"
+
+// dryRunClient sends the write requests of the reconcilers with the server side dry-run
+// option, so that they are validated by the API server without being applied, and logs
+// the changes that would have been made
+type dryRunClient struct {
+	client.Client
+	log logr.Logger
+}
+
+// NewDryRunClient wraps the client of the reconcilers to preview the changes of the
+// controller on a cluster without applying them. The audit records are suppressed, as
+// no change is applied
+func NewDryRunClient(c client.Client, log logr.Logger) client.Client {
+	auditSuppressed = true
+	return &dryRunClient{Client: c, log: log}
+}
+
+// redactSensitiveFields replaces the values of the fields holding credentials or private keys
+// of the JSON object of the given kind: the data of the Secrets and the key of the Routes
+func redactSensitiveFields(kind schema.GroupKind, obj map[string]interface{}) {
+	switch kind {
+	case schema.GroupKind{Group: "", Kind: "Secret"}:
+		for _, field := range []string{"data", "stringData"} {
+			if data, ok := obj[field].(map[string]interface{}); ok {
+				for key := range data {
+					data[key] = redactedValue
+				}
+			}
+		}
+	case schema.GroupKind{Group: "route.openshift.io", Kind: "Route"}:
+		if spec, ok := obj["spec"].(map[string]interface{}); ok {
+			if tls, ok := spec["tls"].(map[string]interface{}); ok {
+				if _, ok := tls["key"]; ok {
+					tls["key"] = redactedValue
+				}
+			}
+		}
+	}
+}
+
+// delta returns the JSON merge patch from the existing object to the desired one, the whole
+// desired object when it does not exist yet
+func (c *dryRunClient) delta(ctx context.Context, obj client.Object, kind schema.GroupKind) (string, error) {
+	existing := obj.DeepCopyObject().(client.Object)
+	err := c.Client.Get(ctx, client.ObjectKeyFromObject(obj), existing)
+	if err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return "", err
+		}
+		// The patch from an empty object holds all the fields of the desired object
+		existing = reflect.New(reflect.TypeOf(obj).Elem()).Interface().(client.Object)
+	}
+	patch, err := client.MergeFrom(existing).Data(obj)
+	if err != nil {
+		return "", err
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(patch, &fields); err != nil {
+		return "", err
+	}
+	redactSensitiveFields(kind, fields)
+	redacted, err := json.Marshal(fields)
+	return string(redacted), err
+}
+
+func (c *dryRunClient) logChange(ctx context.Context, action string, obj client.Object) {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if objGVK, err := apiutil.GVKForObject(obj, c.Scheme()); err == nil {
+		gvk = objGVK
+	}
+	keysAndValues := []interface{}{"action", action, "kind", gvk.Kind,
+		"name", obj.GetName(), "namespace", obj.GetNamespace()}
+	if action != AuditActionDelete {
+		delta, err := c.delta(ctx, obj, gvk.GroupKind())
+		if err != nil {
+			c.log.Error(err, "Dry-run: unable to compute the change", keysAndValues...)
+		} else {
+			keysAndValues = append(keysAndValues, "delta", delta)
+		}
+	}
+	c.log.Info("Dry-run: change not applied", keysAndValues...)
+}
+
+func (c *dryRunClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	c.logChange(ctx, AuditActionCreate, obj)
+	return c.Client.Create(ctx, obj, append(opts, client.DryRunAll)...)
+}
+
+func (c *dryRunClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c.logChange(ctx, AuditActionUpdate, obj)
+	return c.Client.Update(ctx, obj, append(opts, client.DryRunAll)...)
+}
+
+func (c *dryRunClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.logChange(ctx, AuditActionUpdate, obj)
+	return c.Client.Patch(ctx, obj, patch, append(opts, client.DryRunAll)...)
+}
+
+func (c *dryRunClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	c.logChange(ctx, AuditActionDelete, obj)
+	return c.Client.Delete(ctx, obj, append(opts, client.DryRunAll)...)
+}
+
+func (c *dryRunClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	c.logChange(ctx, AuditActionDelete, obj)
+	return c.Client.DeleteAllOf(ctx, obj, append(opts, client.DryRunAll)...)
+}
+
+// Status returns the status writer of the client, with the dry-run option as well
+func (c *dryRunClient) Status() client.StatusWriter {
+	return &dryRunStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+// dryRunStatusWriter sends the status updates with the server side dry-run option
+type dryRunStatusWriter struct {
+	client.StatusWriter
+	client *dryRunClient
+}
+
+func (w *dryRunStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	w.client.logChange(ctx, AuditActionUpdate, obj)
+	return w.StatusWriter.Update(ctx, obj, append(opts, client.DryRunAll)...)
+}
+
+func (w *dryRunStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+	w.client.logChange(ctx, AuditActionUpdate, obj)
+	return w.StatusWriter.Patch(ctx, obj, patch, append(opts, client.DryRunAll)...)
+}
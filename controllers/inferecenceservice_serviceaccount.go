@@ -48,6 +48,7 @@ func createDelegateClusterRoleBinding(serviceAccountName string, serviceAccountN
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      serviceAccountNamespace + "-" + serviceAccountName + "-auth-delegator",
 			Namespace: serviceAccountNamespace,
+			Labels:    managedLabels(serviceAccountNamespace, map[string]string{"opendatahub.io/managed": "true"}),
 		},
 		Subjects: []authv1.Subject{
 			authv1.Subject{
@@ -100,6 +101,12 @@ func (r *OpenshiftInferenceServiceReconciler) reconcileSA(inferenceService *infe
 
 	// Create the corresponding auth delegation cluster role binding
 	desiredCRB := createDelegateClusterRoleBinding(modelMeshServiceAccountName, desiredSA.Namespace)
+	// The CRB is shared by the namespace, only the namespace labels are propagated
+	if err := r.addPropagatedLabels(ctx, desiredCRB, nil); err != nil {
+		log.Error(err, "Unable to get the labels to propagate to the Auth Delegation Cluster Role Binding")
+		return err
+	}
+	excludeFromBackup(desiredCRB, r.ExcludeFromBackup)
 	foundCRB := &authv1.ClusterRoleBinding{}
 	justCreated := false
 
@@ -124,6 +131,7 @@ func (r *OpenshiftInferenceServiceReconciler) reconcileSA(inferenceService *infe
 				log.Error(err, "Unable to create the Auth Delegation Cluster Role Binding")
 				return err
 			}
+			auditLog(AuditActionCreate, "ClusterRoleBinding", desiredCRB, "Auth delegation granted to "+desiredSA.Name)
 			justCreated = true
 		} else {
 			log.Error(err, "Unable to fetch the Auth Delegation Cluster Role Binding")
@@ -132,6 +140,9 @@ func (r *OpenshiftInferenceServiceReconciler) reconcileSA(inferenceService *infe
 	}
 
 	// Reconcile the CRB spec if it has been manually modified
+	if !justCreated && r.pausedByUsers(foundCRB, inferenceService, log) {
+		return nil
+	}
 	if !justCreated && !CompareInferenceServiceCRBs(*desiredCRB, *foundCRB) {
 		log.Info("Reconciling Auth Delegation Cluster Role Binding")
 		// Retry the update operation when the ingress controller eventually
@@ -147,12 +158,14 @@ func (r *OpenshiftInferenceServiceReconciler) reconcileSA(inferenceService *infe
 			// Reconcile labels and spec field
 			foundCRB.Subjects = desiredCRB.Subjects
 			foundCRB.RoleRef = desiredCRB.RoleRef
-			return r.Update(ctx, desiredCRB)
+			foundCRB.ObjectMeta.Labels = desiredCRB.ObjectMeta.Labels
+			return r.Update(ctx, foundCRB)
 		})
 		if err != nil {
 			log.Error(err, "Unable to reconcile the Auth Delegation Cluster Role Binding")
 			return err
 		}
+		auditLog(AuditActionUpdate, "ClusterRoleBinding", desiredCRB, "Cluster Role Binding reverted to the desired state")
 	}
 	return nil
 }
@@ -165,7 +178,8 @@ func (r *OpenshiftInferenceServiceReconciler) ReconcileSA(
 
 // CompareInferenceServiceCRBs checks if two service accounts are equal, if not return false
 func CompareInferenceServiceCRBs(crb1 authv1.ClusterRoleBinding, crb2 authv1.ClusterRoleBinding) bool {
-	// Two CRBs will be equal if the role reference and subjects are equal
-	return reflect.DeepEqual(crb1.RoleRef, crb2.RoleRef) &&
+	// Two CRBs will be equal if the labels, role reference and subjects are equal
+	return reflect.DeepEqual(crb1.ObjectMeta.Labels, crb2.ObjectMeta.Labels) &&
+		reflect.DeepEqual(crb1.RoleRef, crb2.RoleRef) &&
 		reflect.DeepEqual(crb1.Subjects, crb2.Subjects)
 }
@@ -21,16 +21,24 @@ import (
 	predictorv1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
 	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
 	routev1 "github.com/openshift/api/route/v1"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	authv1 "k8s.io/api/rbac/v1"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
+	maistrav1 "maistra.io/api/core/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlbuilder "sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
+	"time"
 )
 
 // OpenshiftInferenceServiceReconciler holds the controller configuration.
@@ -39,6 +47,61 @@ type OpenshiftInferenceServiceReconciler struct {
 	Scheme       *runtime.Scheme
 	Log          logr.Logger
 	MeshDisabled bool
+	// MeshControlPlane is the ServiceMeshControlPlane the namespaces are enrolled in,
+	// DefaultMeshControlPlane when empty
+	MeshControlPlane types.NamespacedName
+	// RouteDisabled skips the Route reconciliation, e.g. when the cluster does not
+	// serve the Openshift Route API
+	RouteDisabled bool
+	// IngressClassName enables the exposure of the models with Ingresses of this
+	// class instead of Routes, for non-Openshift clusters
+	IngressClassName string
+	// RouteTLSPolicy configures the TLS of the routes, the default policy when empty
+	RouteTLSPolicy RouteTLSPolicy
+	// PrometheusRuleDisabled skips the SLO recording rules generation when the cluster
+	// does not serve the PrometheusRule API
+	PrometheusRuleDisabled bool
+	// SLOWindow is the rate window of the SLO recording rules, DefaultSLOWindow if empty
+	SLOWindow string
+	// AcceleratorProfileDisabled skips the injection of the AcceleratorProfiles in the
+	// serving runtimes when the cluster does not serve the AcceleratorProfile API
+	AcceleratorProfileDisabled bool
+	// AcceleratorProfileNS is the namespace of the AcceleratorProfiles shared by all the
+	// namespaces, usually the namespace of the ODH dashboard
+	AcceleratorProfileNS string
+	// PropagatedLabels are the keys of the labels (e.g. tenant, cost-center) copied from the
+	// InferenceService, or its namespace, to the resources created by the controller
+	PropagatedLabels []string
+	// ExcludeFromBackup labels the resources created by the controller to be excluded from
+	// the Velero backups, they are regenerated from the InferenceServices after a restore
+	ExcludeFromBackup bool
+	// RuntimePullSecret is the pull Secret of the private registry of the serving runtimes,
+	// replicated in the namespaces of their models. Disabled when empty
+	RuntimePullSecret types.NamespacedName
+	// InjectProxy injects ProxyEnv in the serving runtimes, the injected variables are
+	// removed when ProxyEnv is empty
+	InjectProxy bool
+	// ProxyEnv are the egress proxy variables injected in the serving runtimes
+	ProxyEnv []corev1.EnvVar
+	// Recorder records the events of the InferenceServices, e.g. when the exposed models
+	// quota of their namespace is reached. The manager one is used when nil
+	Recorder record.EventRecorder
+	// APIReader reads from the API server the resources that must not be read from the
+	// cache, e.g. the exposed InferenceServices counted against the quota of their
+	// namespace. The manager one is used when nil
+	APIReader client.Reader
+	// MaintenanceResponderIP and MaintenanceResponderPort are the address of the
+	// MaintenanceResponder of the replica, set as endpoint of the maintenance Services. They
+	// get no endpoint when the IP is empty, the router then answers 503 without Retry-After
+	MaintenanceResponderIP   string
+	MaintenanceResponderPort int32
+	// SubReconcilerLogger returns the logger of the sub-reconciler with the name, e.g.
+	// "route", so that its level can be tuned separately. Log.WithName(name) when nil
+	SubReconcilerLogger func(name string) logr.Logger
+
+	breaker *circuitBreaker
+	// scoped are the copies of the reconciler running each sub-reconciler with its logger
+	scoped map[string]*OpenshiftInferenceServiceReconciler
 }
 
 // ClusterRole permissions
@@ -51,10 +114,18 @@ type OpenshiftInferenceServiceReconciler struct {
 // +kubebuilder:rbac:groups=networking.istio.io,resources=virtualservices/finalizers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=maistra.io,resources=servicemeshmembers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=maistra.io,resources=servicemeshmembers/finalizers,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=config.openshift.io,resources=proxies,verbs=get;list;watch
 // +kubebuilder:rbac:groups=maistra.io,resources=servicemeshcontrolplanes,verbs=get;list;watch;create;update;patch;use
-// +kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=route.openshift.io,resources=routes/custom-host,verbs=create
+// +kubebuilder:rbac:groups=dashboard.opendatahub.io,resources=acceleratorprofiles,verbs=get;list;watch
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=prometheusrules,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterrolebindings,verbs=get;list;watch;create;update;patch;watch;delete
 // +kubebuilder:rbac:groups="",resources=configmaps;namespaces;pods;services;serviceaccounts;secrets,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=endpoints,verbs=get;create;update;delete
+// +kubebuilder:rbac:groups="",resources=services,verbs=delete
 
 // Reconcile performs the reconciling of the Openshift objects for a Kubeflow
 // InferenceService.
@@ -68,47 +139,217 @@ func (r *OpenshiftInferenceServiceReconciler) Reconcile(ctx context.Context, req
 	err := r.Get(ctx, req.NamespacedName, inferenceservice)
 	if err != nil && apierrs.IsNotFound(err) {
 		log.Info("Stop InferenceService reconciliation")
+		r.breaker.forget(req.NamespacedName)
+		forgetModelReadiness(req.NamespacedName)
+		if !r.MeshDisabled {
+			// Remove the namespace from the mesh if this was the last InferenceService
+			if err := r.cleanupMeshMember(ctx, req.Namespace); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		if r.RuntimePullSecret.Name != "" {
+			// Remove the pull Secret if this was the last InferenceService using it
+			if err := r.cleanupPullSecret(ctx, req.Namespace); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
 		return ctrl.Result{}, nil
 	} else if err != nil {
 		log.Error(err, "Unable to fetch the InferenceService")
 		return ctrl.Result{}, err
 	}
+	r.recordModelReadiness(ctx, inferenceservice)
 
-	err = r.ReconcileRoute(inferenceservice, ctx)
-	if err != nil {
-		return ctrl.Result{}, err
+	// The sub-reconcilers are independent, a failing one does not prevent the others from
+	// running and is skipped with a backoff once its error budget is exhausted
+	result := ctrl.Result{}
+	errs := []error{}
+	for _, sub := range r.subReconcilers() {
+		if !sub.enabled {
+			continue
+		}
+		if allowed, remaining := r.breaker.allow(req.NamespacedName, sub.name); !allowed {
+			log.Info("Skipping failing sub-reconciler until its backoff expires", "subReconciler", sub.name,
+				"remaining", remaining.String())
+			result = requeueAfter(result, remaining)
+			continue
+		}
+		if err := sub.reconcile(r.scopedReconciler(sub.name), inferenceservice, ctx); err != nil {
+			r.Recorder.Eventf(inferenceservice, corev1.EventTypeWarning, subReconcilerFailedReason,
+				"Unable to reconcile the %s resources: %v", sub.name, err)
+			backoff := r.breaker.failure(req.NamespacedName, sub.name)
+			if backoff == 0 {
+				errs = append(errs, &subReconcilerError{subReconciler: sub.name, err: err})
+				continue
+			}
+			log.Error(err, "Sub-reconciler keeps failing, skipping it", "subReconciler", sub.name,
+				"backoff", backoff.String())
+			result = requeueAfter(result, backoff)
+			continue
+		}
+		r.breaker.success(req.NamespacedName, sub.name)
+	}
+	// The sub-reconcilers that succeeded compare their resources before updating them, so
+	// they are no-ops when the failing ones are retried
+	if len(errs) > 0 {
+		return ctrl.Result{}, utilerrors.NewAggregate(errs)
 	}
+	return result, nil
+}
 
-	err = r.ReconcileSA(inferenceservice, ctx)
-	if err != nil {
-		return ctrl.Result{}, err
+// subReconciler reconciles one of the resources of an InferenceService
+type subReconciler struct {
+	name      string
+	reconcile func(*OpenshiftInferenceServiceReconciler, *inferenceservicev1.InferenceService, context.Context) error
+	enabled   bool
+}
+
+// subReconcilers returns the sub-reconcilers of the InferenceServices in their order, their
+// names are the ones of their loggers and events
+func (r *OpenshiftInferenceServiceReconciler) subReconcilers() []subReconciler {
+	return []subReconciler{
+		{"maintenance", (*OpenshiftInferenceServiceReconciler).ReconcileMaintenance, true},
+		{"ingress", (*OpenshiftInferenceServiceReconciler).ReconcileIngress, r.IngressClassName != ""},
+		{"route", (*OpenshiftInferenceServiceReconciler).ReconcileRoute, r.IngressClassName == "" && !r.RouteDisabled},
+		{"serviceaccount", (*OpenshiftInferenceServiceReconciler).ReconcileSA, true},
+		{"pullsecret", (*OpenshiftInferenceServiceReconciler).ReconcilePullSecret, r.RuntimePullSecret.Name != ""},
+		{"probes", (*OpenshiftInferenceServiceReconciler).ReconcileServingRuntimeProbes, true},
+		{"accelerator", (*OpenshiftInferenceServiceReconciler).ReconcileAcceleratorProfile, !r.AcceleratorProfileDisabled},
+		{"proxy", (*OpenshiftInferenceServiceReconciler).ReconcileServingRuntimeProxy, r.InjectProxy},
+		{"slo", (*OpenshiftInferenceServiceReconciler).ReconcileSLORules, !r.PrometheusRuleDisabled},
+		{"meshmember", (*OpenshiftInferenceServiceReconciler).ReconcileMeshMember, !r.MeshDisabled},
+		{"export", (*OpenshiftInferenceServiceReconciler).ReconcileExport, true},
 	}
+}
+
+// scopedReconciler returns the copy of the reconciler logging with the logger of the
+// sub-reconciler, the reconciler itself when it was not set up with the manager
+func (r *OpenshiftInferenceServiceReconciler) scopedReconciler(name string) *OpenshiftInferenceServiceReconciler {
+	if scoped, ok := r.scoped[name]; ok {
+		return scoped
+	}
+	return r
+}
+
+// subReconcilerFailedReason is the reason of the events of the failing sub-reconcilers
+const subReconcilerFailedReason = "ReconcileFailed"
 
-	return ctrl.Result{}, nil
+// subReconcilerError is the failure of a sub-reconciler, the failures of all the
+// sub-reconcilers of an InferenceService are aggregated in the error of its reconciliation
+type subReconcilerError struct {
+	subReconciler string
+	err           error
+}
+
+func (e *subReconcilerError) Error() string {
+	return e.subReconciler + " sub-reconciler: " + e.err.Error()
+}
+
+func (e *subReconcilerError) Unwrap() error {
+	return e.err
+}
+
+// requeueAfter returns the result requeuing at the earliest of its delay and the given one
+func requeueAfter(result ctrl.Result, delay time.Duration) ctrl.Result {
+	if result.RequeueAfter == 0 || delay < result.RequeueAfter {
+		result.RequeueAfter = delay
+	}
+	return result
+}
+
+// inferenceServicesRequests returns the reconcile requests of the listed InferenceServices
+func inferenceServicesRequests(inferenceServicesList *inferenceservicev1.InferenceServiceList) []reconcile.Request {
+	reconcileRequests := make([]reconcile.Request, 0, len(inferenceServicesList.Items))
+	for _, inferenceService := range inferenceServicesList.Items {
+		reconcileRequests = append(reconcileRequests, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      inferenceService.Name,
+				Namespace: inferenceService.Namespace,
+			},
+		})
+	}
+	return reconcileRequests
+}
+
+// requeueNamespaceInferenceServices returns the reconcile requests of all the InferenceServices
+// of the namespace, used when a namespace level resource managed by the controller drifts
+func (r *OpenshiftInferenceServiceReconciler) requeueNamespaceInferenceServices(namespace string) []reconcile.Request {
+	inferenceServicesList := &inferenceservicev1.InferenceServiceList{}
+	err := r.List(context.TODO(), inferenceServicesList, client.InNamespace(namespace))
+	if err != nil {
+		r.Log.Info("Error getting list of inference services for namespace " + namespace)
+		return []reconcile.Request{}
+	}
+	return inferenceServicesRequests(inferenceServicesList)
 }
 
+// inferenceServiceRuntimeField indexes the InferenceServices by the name of the
+// serving runtime they are deployed on, autoSelectedRuntimeIndexValue when ModelMesh
+// selects it
+const inferenceServiceRuntimeField = "spec.predictor.model.runtime"
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *OpenshiftInferenceServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.breaker = newCircuitBreaker()
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorderFor("odh-model-controller")
+	}
+	if r.APIReader == nil {
+		r.APIReader = mgr.GetAPIReader()
+	}
+	r.scoped = map[string]*OpenshiftInferenceServiceReconciler{}
+	for _, sub := range r.subReconcilers() {
+		scoped := *r
+		scoped.Log = r.Log.WithName(sub.name)
+		if r.SubReconcilerLogger != nil {
+			scoped.Log = r.SubReconcilerLogger(sub.name)
+		}
+		r.scoped[sub.name] = &scoped
+	}
+
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &inferenceservicev1.InferenceService{},
+		inferenceServiceRuntimeField, func(o client.Object) []string {
+			return []string{inferenceServiceRuntimeIndexValue(o.(*inferenceservicev1.InferenceService))}
+		})
+	if err != nil {
+		return err
+	}
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &inferenceservicev1.InferenceService{},
+		inferenceServiceAcceleratorField, func(o client.Object) []string {
+			if name := o.GetAnnotations()[acceleratorNameAnnotation]; name != "" {
+				return []string{name}
+			}
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+
 	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&inferenceservicev1.InferenceService{}).
 		Owns(&predictorv1.ServingRuntime{}).
 		Owns(&corev1.Namespace{}).
-		Owns(&routev1.Route{}).
 		Owns(&corev1.ServiceAccount{}).
 		Owns(&corev1.Service{}).
 		Owns(&corev1.Secret{}).
-		Owns(&authv1.ClusterRoleBinding{}).
 		Watches(&source.Kind{Type: &predictorv1.ServingRuntime{}},
 			handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
 				r.Log.Info("Reconcile event triggered by serving runtime: " + o.GetName())
 				inferenceServicesList := &inferenceservicev1.InferenceServiceList{}
-				opts := []client.ListOption{client.InNamespace(o.GetNamespace())}
-
-				// Todo: Get only Inference Services that are deploying on the specific serving runtime
-				err := r.List(context.TODO(), inferenceServicesList, opts...)
-				if err != nil {
-					r.Log.Info("Error getting list of inference services for namespace")
-					return []reconcile.Request{}
+				// Get only the Inference Services that are deploying on the specific serving runtime,
+				// or that may be deployed on it through the runtime auto-selection
+				for _, runtime := range []string{o.GetName(), autoSelectedRuntimeIndexValue} {
+					runtimeInferenceServices := &inferenceservicev1.InferenceServiceList{}
+					opts := []client.ListOption{
+						client.InNamespace(o.GetNamespace()),
+						client.MatchingFields{inferenceServiceRuntimeField: runtime},
+					}
+					err := r.List(context.TODO(), runtimeInferenceServices, opts...)
+					if err != nil {
+						r.Log.Info("Error getting list of inference services for namespace")
+						return []reconcile.Request{}
+					}
+					inferenceServicesList.Items = append(inferenceServicesList.Items, runtimeInferenceServices.Items...)
 				}
 
 				if len(inferenceServicesList.Items) == 0 {
@@ -116,18 +357,67 @@ func (r *OpenshiftInferenceServiceReconciler) SetupWithManager(mgr ctrl.Manager)
 					return []reconcile.Request{}
 				}
 
-				reconcileRequests := make([]reconcile.Request, 0, len(inferenceServicesList.Items))
-				for _, inferenceService := range inferenceServicesList.Items {
-					reconcileRequests = append(reconcileRequests, reconcile.Request{
-						NamespacedName: types.NamespacedName{
-							Name:      inferenceService.Name,
-							Namespace: inferenceService.Namespace,
-						},
-					})
+				return inferenceServicesRequests(inferenceServicesList)
+			})).
+		// Watch for the cluster scoped auth delegation ClusterRoleBindings, the owner references
+		// cannot be used to map them to the InferenceServices of their namespace
+		Watches(&source.Kind{Type: &authv1.ClusterRoleBinding{}},
+			handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+				crb := o.(*authv1.ClusterRoleBinding)
+				if !checkOpenDataHubLabel(crb.Labels) || len(crb.Subjects) == 0 {
+					return []reconcile.Request{}
 				}
-				return reconcileRequests
+				return r.requeueNamespaceInferenceServices(crb.Subjects[0].Namespace)
+			})).
+		// The InferenceServices not exposed because of the quota of their namespace are
+		// exposed when the quota is raised or another InferenceService stops being exposed
+		Watches(&source.Kind{Type: &corev1.Namespace{}},
+			handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+				return r.requeueNamespaceInferenceServices(o.GetName())
+			}), ctrlbuilder.WithPredicates(exposureQuotaChanged()))
+	if r.RouteTLSPolicy.CertificateSecretName != "" && r.IngressClassName == "" && !r.RouteDisabled {
+		// The routes serve the rotated certificates of their namespace
+		builder = builder.Watches(&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.requeueRouteCertificateInferenceServices))
+	}
+	if r.RuntimePullSecret.Name != "" {
+		// The copies of the pull Secret are updated when it is modified, and have no owner
+		// as they are shared by the namespace
+		builder = builder.Watches(&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.requeuePullSecretInferenceServices))
+	}
+	if !r.MeshDisabled {
+		// The ServiceMeshMember is shared by the namespace and has no owner
+		builder = builder.Watches(&source.Kind{Type: &maistrav1.ServiceMeshMember{}},
+			handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+				if !checkOpenDataHubLabel(o.GetLabels()) {
+					return []reconcile.Request{}
+				}
+				return r.requeueNamespaceInferenceServices(o.GetNamespace())
 			}))
-	err := builder.Complete(r)
+	}
+	if !r.AcceleratorProfileDisabled {
+		acceleratorProfile := &unstructured.Unstructured{}
+		acceleratorProfile.SetGroupVersionKind(acceleratorProfileGVK)
+		builder = builder.Watches(&source.Kind{Type: acceleratorProfile},
+			handler.EnqueueRequestsFromMapFunc(r.requeueAcceleratorProfileInferenceServices))
+	}
+	if !r.PrometheusRuleDisabled {
+		builder = builder.Owns(&monitoringv1.PrometheusRule{})
+	}
+	requeueNamespace := handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+		return r.requeueNamespaceInferenceServices(o.GetNamespace())
+	})
+	if r.IngressClassName != "" {
+		builder = builder.Owns(&networkingv1.Ingress{}).
+			Watches(&source.Kind{Type: &networkingv1.Ingress{}}, requeueNamespace,
+				ctrlbuilder.WithPredicates(exposureReleased()))
+	} else if !r.RouteDisabled {
+		builder = builder.Owns(&routev1.Route{}).
+			Watches(&source.Kind{Type: &routev1.Route{}}, requeueNamespace,
+				ctrlbuilder.WithPredicates(exposureReleased()))
+	}
+	err = builder.Complete(r)
 	if err != nil {
 		return err
 	}
@@ -17,10 +17,16 @@ package controllers
 
 import (
 	"context"
+	"strings"
+
 	mmv1alpha1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
 	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
 	routev1 "github.com/openshift/api/route/v1"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	mfc "github.com/manifestival/controller-runtime-client"
 	mf "github.com/manifestival/manifestival"
@@ -62,4 +68,267 @@ var _ = Describe("The Openshift model controller", func() {
 			Expect(CompareInferenceServiceRoutes(*route, *expectedRoute)).Should(BeTrue())
 		})
 	})
+
+	Context("When creating a ServiceRuntime with 'enable-probes' enabled & an InferenceService", func() {
+
+		It("Should inject the health probes in the runtime containers", func() {
+			client := mfc.NewClient(cli)
+			opts := mf.UseClient(client)
+			ctx := context.Background()
+
+			servingRuntime := &mmv1alpha1.ServingRuntime{}
+			err := convertToStructuredResource(ServingRuntimePath1, servingRuntime, opts)
+			Expect(err).NotTo(HaveOccurred())
+			servingRuntime.Annotations["enable-probes"] = "true"
+			// The model server must listen on the pod IP to be probed by the kubelet
+			args := []string{}
+			for _, arg := range servingRuntime.Spec.Containers[0].Args {
+				if !strings.HasPrefix(arg, "--rest_bind_address") {
+					args = append(args, arg)
+				}
+			}
+			servingRuntime.Spec.Containers[0].Args = args
+			Expect(cli.Create(ctx, servingRuntime)).Should(Succeed())
+
+			inferenceService := &inferenceservicev1.InferenceService{}
+			err = convertToStructuredResource(InferenceService1, inferenceService, opts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cli.Create(ctx, inferenceService)).Should(Succeed())
+
+			By("By checking that the controller has injected the probes")
+
+			Eventually(func() bool {
+				key := types.NamespacedName{Name: servingRuntime.Name, Namespace: servingRuntime.Namespace}
+				if err := cli.Get(ctx, key, servingRuntime); err != nil {
+					return false
+				}
+				return servingRuntime.Spec.Containers[0].ReadinessProbe != nil
+			}, timeout, interval).Should(BeTrue())
+
+			container := servingRuntime.Spec.Containers[0]
+			Expect(container.ReadinessProbe.HTTPGet.Path).To(Equal("/v2/health/ready"))
+			Expect(container.ReadinessProbe.HTTPGet.Port.IntValue()).To(Equal(8888))
+			Expect(container.LivenessProbe).NotTo(BeNil())
+		})
+	})
+
+	Context("When creating an InferenceService with SLO annotations", func() {
+
+		It("Should create a PrometheusRule recording the SLO indicators", func() {
+			client := mfc.NewClient(cli)
+			opts := mf.UseClient(client)
+			ctx := context.Background()
+
+			servingRuntime := &mmv1alpha1.ServingRuntime{}
+			err := convertToStructuredResource(ServingRuntimePath1, servingRuntime, opts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cli.Create(ctx, servingRuntime)).Should(Succeed())
+
+			inferenceService := &inferenceservicev1.InferenceService{}
+			err = convertToStructuredResource(InferenceService1, inferenceService, opts)
+			Expect(err).NotTo(HaveOccurred())
+			inferenceService.Annotations[sloLatencyP95Annotation] = "2s"
+			Expect(cli.Create(ctx, inferenceService)).Should(Succeed())
+
+			By("By checking that the controller has created the PrometheusRule")
+
+			rule := &monitoringv1.PrometheusRule{}
+			Eventually(func() error {
+				key := types.NamespacedName{Name: inferenceService.Name + "-slo", Namespace: inferenceService.Namespace}
+				return cli.Get(ctx, key, rule)
+			}, timeout, interval).ShouldNot(HaveOccurred())
+
+			Expect(rule.Spec.Groups).To(HaveLen(1))
+			Expect(rule.Spec.Groups[0].Rules).To(HaveLen(3))
+			Expect(rule.Spec.Groups[0].Rules[2].Expr.String()).To(Equal("vector(2)"))
+		})
+	})
+
+	Context("When creating an InferenceService before its ServiceRuntime with 'enable-route' enabled", func() {
+
+		It("Should create the Route once the ServingRuntime is created", func() {
+			client := mfc.NewClient(cli)
+			opts := mf.UseClient(client)
+			ctx := context.Background()
+
+			inferenceService := &inferenceservicev1.InferenceService{}
+			err := convertToStructuredResource(InferenceService1, inferenceService, opts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cli.Create(ctx, inferenceService)).Should(Succeed())
+
+			servingRuntime := &mmv1alpha1.ServingRuntime{}
+			err = convertToStructuredResource(ServingRuntimePath1, servingRuntime, opts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cli.Create(ctx, servingRuntime)).Should(Succeed())
+
+			By("By checking that the ServingRuntime event has triggered the Route creation")
+
+			route := &routev1.Route{}
+			Eventually(func() error {
+				key := types.NamespacedName{Name: inferenceService.Name, Namespace: inferenceService.Namespace}
+				return cli.Get(ctx, key, route)
+			}, timeout, interval).ShouldNot(HaveOccurred())
+		})
+	})
+
+	Context("When creating a ServiceRuntime with 'enable-route-rewrite' enabled & an InferenceService", func() {
+
+		It("Should serve the model endpoints at the root of the Route host", func() {
+			client := mfc.NewClient(cli)
+			opts := mf.UseClient(client)
+			ctx := context.Background()
+
+			servingRuntime := &mmv1alpha1.ServingRuntime{}
+			err := convertToStructuredResource(ServingRuntimePath1, servingRuntime, opts)
+			Expect(err).NotTo(HaveOccurred())
+			servingRuntime.Annotations["enable-route-rewrite"] = "true"
+			Expect(cli.Create(ctx, servingRuntime)).Should(Succeed())
+
+			inferenceService := &inferenceservicev1.InferenceService{}
+			err = convertToStructuredResource(InferenceService1, inferenceService, opts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cli.Create(ctx, inferenceService)).Should(Succeed())
+
+			By("By checking that the Route rewrites the requests to the model path")
+
+			routeKey := types.NamespacedName{
+				Name:      inferenceServiceRouteName(inferenceService),
+				Namespace: inferenceService.Namespace,
+			}
+			route := &routev1.Route{}
+			Eventually(func() error {
+				return cli.Get(ctx, routeKey, route)
+			}, timeout, interval).ShouldNot(HaveOccurred())
+			Expect(route.Spec.Path).To(Equal("/"))
+			Expect(route.Annotations).To(HaveKeyWithValue(routeRewriteTargetAnnotation,
+				inferenceServiceModelPath(inferenceService)+"/"))
+
+			By("By checking that the Route serves the model path once the rewrite is disabled")
+
+			key := types.NamespacedName{Name: servingRuntime.Name, Namespace: servingRuntime.Namespace}
+			Expect(cli.Get(ctx, key, servingRuntime)).Should(Succeed())
+			delete(servingRuntime.Annotations, "enable-route-rewrite")
+			Expect(cli.Update(ctx, servingRuntime)).Should(Succeed())
+
+			Eventually(func() (string, error) {
+				err := cli.Get(ctx, routeKey, route)
+				return route.Spec.Path, err
+			}, timeout, interval).Should(Equal(inferenceServiceModelPath(inferenceService)))
+			Expect(route.Annotations).NotTo(HaveKey(routeRewriteTargetAnnotation))
+		})
+	})
+
+	Context("When an InferenceService is annotated for maintenance", func() {
+
+		It("Should switch its Route to the maintenance Service until the annotation is removed", func() {
+			client := mfc.NewClient(cli)
+			opts := mf.UseClient(client)
+			ctx := context.Background()
+
+			servingRuntime := &mmv1alpha1.ServingRuntime{}
+			err := convertToStructuredResource(ServingRuntimePath1, servingRuntime, opts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cli.Create(ctx, servingRuntime)).Should(Succeed())
+
+			inferenceService := &inferenceservicev1.InferenceService{}
+			err = convertToStructuredResource(InferenceService1, inferenceService, opts)
+			Expect(err).NotTo(HaveOccurred())
+			if inferenceService.Annotations == nil {
+				inferenceService.Annotations = map[string]string{}
+			}
+			inferenceService.Annotations[maintenanceAnnotation] = "true"
+			Expect(cli.Create(ctx, inferenceService)).Should(Succeed())
+
+			By("By checking that the Route is switched to the maintenance Service")
+
+			maintenanceKey := types.NamespacedName{
+				Name:      inferenceServiceMaintenanceServiceName(inferenceService),
+				Namespace: inferenceService.Namespace,
+			}
+			routeKey := types.NamespacedName{
+				Name:      inferenceServiceRouteName(inferenceService),
+				Namespace: inferenceService.Namespace,
+			}
+			Eventually(func() error {
+				return cli.Get(ctx, maintenanceKey, &corev1.Service{})
+			}, timeout, interval).ShouldNot(HaveOccurred())
+			Eventually(func() ([]corev1.EndpointSubset, error) {
+				endpoints := &corev1.Endpoints{}
+				err := cli.Get(ctx, maintenanceKey, endpoints)
+				return endpoints.Subsets, err
+			}, timeout, interval).Should(Equal(NewInferenceServiceMaintenanceEndpoints(inferenceService,
+				"10.0.0.1", 8090).Subsets))
+			Eventually(func() (string, error) {
+				route := &routev1.Route{}
+				err := cli.Get(ctx, routeKey, route)
+				return route.Spec.To.Name, err
+			}, timeout, interval).Should(Equal(maintenanceKey.Name))
+
+			By("By checking that the InferenceService is back online once the annotation is removed")
+
+			key := types.NamespacedName{Name: inferenceService.Name, Namespace: inferenceService.Namespace}
+			Expect(cli.Get(ctx, key, inferenceService)).Should(Succeed())
+			delete(inferenceService.Annotations, maintenanceAnnotation)
+			Expect(cli.Update(ctx, inferenceService)).Should(Succeed())
+
+			Eventually(func() bool {
+				return apierrs.IsNotFound(cli.Get(ctx, maintenanceKey, &corev1.Service{}))
+			}, timeout, interval).Should(BeTrue())
+			Expect(apierrs.IsNotFound(cli.Get(ctx, maintenanceKey, &corev1.Endpoints{}))).To(BeTrue())
+			Eventually(func() (string, error) {
+				route := &routev1.Route{}
+				err := cli.Get(ctx, routeKey, route)
+				return route.Spec.To.Name, err
+			}, timeout, interval).Should(Equal(modelmeshServiceName))
+		})
+	})
+
+	Context("When the exposed models quota of the namespace is reached", func() {
+
+		It("Should not expose the InferenceService until the quota is raised", func() {
+			opts := mf.UseClient(mfc.NewClient(cli))
+			ctx := context.Background()
+
+			ns := &corev1.Namespace{}
+			Expect(cli.Get(ctx, types.NamespacedName{Name: WorkingNamespace}, ns)).Should(Succeed())
+			if ns.Annotations == nil {
+				ns.Annotations = map[string]string{}
+			}
+			ns.Annotations[exposedModelsQuotaAnnotation] = "0"
+			Expect(cli.Update(ctx, ns)).Should(Succeed())
+
+			servingRuntime := &mmv1alpha1.ServingRuntime{}
+			err := convertToStructuredResource(ServingRuntimePath1, servingRuntime, opts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cli.Create(ctx, servingRuntime)).Should(Succeed())
+
+			inferenceService := &inferenceservicev1.InferenceService{}
+			err = convertToStructuredResource(InferenceService1, inferenceService, opts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cli.Create(ctx, inferenceService)).Should(Succeed())
+
+			By("By checking that the quota is reported on the InferenceService")
+
+			key := types.NamespacedName{Name: inferenceService.Name, Namespace: inferenceService.Namespace}
+			Eventually(func() (int, error) {
+				events := &corev1.EventList{}
+				err := cli.List(ctx, events, client.InNamespace(WorkingNamespace), client.MatchingFields{
+					"involvedObject.name": inferenceService.Name,
+					"reason":              exposureQuotaExceededReason,
+				})
+				return len(events.Items), err
+			}, timeout, interval).ShouldNot(BeZero())
+			Expect(apierrs.IsNotFound(cli.Get(ctx, key, &routev1.Route{}))).To(BeTrue())
+
+			By("By checking that the InferenceService is exposed once the quota is raised")
+
+			Expect(cli.Get(ctx, types.NamespacedName{Name: WorkingNamespace}, ns)).Should(Succeed())
+			delete(ns.Annotations, exposedModelsQuotaAnnotation)
+			Expect(cli.Update(ctx, ns)).Should(Succeed())
+
+			Eventually(func() error {
+				return cli.Get(ctx, key, &routev1.Route{})
+			}, timeout, interval).ShouldNot(HaveOccurred())
+		})
+	})
 })
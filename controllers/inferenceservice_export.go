@@ -0,0 +1,211 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	predictorv1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	routev1 "github.com/openshift/api/route/v1"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	authv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	maistrav1 "maistra.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// exportAnnotation requests the export of the resources managed for the InferenceService,
+	// its value (e.g. a timestamp) is recorded in the export to tell the requests apart
+	exportAnnotation = "serving.opendatahub.io/export"
+	// exportDataKey is the key of the YAML bundle in the export ConfigMap
+	exportDataKey = "resources.yaml"
+)
+
+// inferenceServiceExportName returns the name of the ConfigMap holding the export
+func inferenceServiceExportName(inferenceservice *inferenceservicev1.InferenceService) string {
+	return childName(inferenceservice.Name, "export")
+}
+
+// managedResources returns the InferenceService and the resources managed by the controllers
+// for it, or its namespace, that exist in the cluster
+func (r *OpenshiftInferenceServiceReconciler) managedResources(ctx context.Context,
+	inferenceservice *inferenceservicev1.InferenceService) ([]client.Object, error) {
+	namespace := inferenceservice.Namespace
+	type managedResource struct {
+		obj client.Object
+		key types.NamespacedName
+	}
+	candidates := []managedResource{
+		{&corev1.ServiceAccount{}, types.NamespacedName{Name: modelMeshServiceAccountName, Namespace: namespace}},
+		{&authv1.ClusterRoleBinding{}, types.NamespacedName{Name: namespace + "-" + modelMeshServiceAccountName + "-auth-delegator"}},
+		{&corev1.Service{}, types.NamespacedName{Name: inferenceServiceMaintenanceServiceName(inferenceservice), Namespace: namespace}},
+		{&monitoringv1.ServiceMonitor{}, types.NamespacedName{Name: ServiceMonitorName, Namespace: namespace}},
+		{&authv1.RoleBinding{}, types.NamespacedName{Name: RoleBindingName, Namespace: namespace}},
+	}
+	for _, name := range networkPolicyNames {
+		candidates = append(candidates, managedResource{&networkingv1.NetworkPolicy{}, types.NamespacedName{Name: name, Namespace: namespace}})
+	}
+	runtimeName, err := r.inferenceServiceRuntimeName(ctx, inferenceservice)
+	if err != nil {
+		return nil, err
+	}
+	if runtimeName != "" {
+		servingRuntime := &predictorv1.ServingRuntime{ObjectMeta: metav1.ObjectMeta{Name: runtimeName}}
+		candidates = append(candidates, managedResource{&policyv1.PodDisruptionBudget{}, types.NamespacedName{Name: runtimeDeploymentName(servingRuntime), Namespace: namespace}})
+	}
+	if r.IngressClassName != "" {
+		candidates = append(candidates, managedResource{&networkingv1.Ingress{}, types.NamespacedName{Name: inferenceservice.Name, Namespace: namespace}})
+	} else if !r.RouteDisabled {
+		candidates = append(candidates, managedResource{&routev1.Route{}, types.NamespacedName{Name: inferenceServiceRouteName(inferenceservice), Namespace: namespace}})
+	}
+	if !r.PrometheusRuleDisabled {
+		candidates = append(candidates, managedResource{&monitoringv1.PrometheusRule{}, types.NamespacedName{Name: inferenceServiceSLORuleName(inferenceservice), Namespace: namespace}})
+	}
+	if !r.MeshDisabled {
+		candidates = append(candidates, managedResource{&maistrav1.ServiceMeshMember{}, types.NamespacedName{Name: serviceMeshMemberName, Namespace: namespace}})
+	}
+
+	resources := []client.Object{inferenceservice.DeepCopy()}
+	for _, candidate := range candidates {
+		if err := r.Get(ctx, candidate.key, candidate.obj); err != nil {
+			// The kinds of the optional APIs, e.g. the ServiceMonitors, may not be served
+			if apierrs.IsNotFound(err) || meta.IsNoMatchError(err) {
+				continue
+			}
+			return nil, err
+		}
+		resources = append(resources, candidate.obj)
+	}
+	return resources, nil
+}
+
+// exportResources serializes the resources in a multi-document YAML bundle, without the
+// fields that are only relevant to the API server, nor the private keys of the Routes
+func (r *OpenshiftInferenceServiceReconciler) exportResources(resources []client.Object) (string, error) {
+	documents := make([]string, 0, len(resources))
+	for _, obj := range resources {
+		gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+		if err != nil {
+			return "", err
+		}
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+		obj.SetManagedFields(nil)
+		obj.SetResourceVersion("")
+		fields, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return "", err
+		}
+		redactSensitiveFields(gvk.GroupKind(), fields)
+		document, err := yaml.Marshal(fields)
+		if err != nil {
+			return "", err
+		}
+		documents = append(documents, string(document))
+	}
+	return strings.Join(documents, "---\n"), nil
+}
+
+// ReconcileExport writes the resources managed for the InferenceService in a ConfigMap when
+// the export annotation is set, so that they can be attached to support requests
+func (r *OpenshiftInferenceServiceReconciler) ReconcileExport(inferenceservice *inferenceservicev1.InferenceService,
+	ctx context.Context) error {
+	requestID, requested := inferenceservice.Annotations[exportAnnotation]
+	if !requested {
+		return nil
+	}
+	// Initialize logger format
+	log := r.Log.WithValues("InferenceService", inferenceservice.Name, "namespace", inferenceservice.Namespace)
+
+	// Export the request once, the ConfigMap records the request it was generated for
+	foundExport := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{
+		Name:      inferenceServiceExportName(inferenceservice),
+		Namespace: inferenceservice.Namespace,
+	}, foundExport)
+	if err != nil && !apierrs.IsNotFound(err) {
+		log.Error(err, "Unable to fetch the export ConfigMap")
+		return err
+	}
+	found := err == nil
+	if found {
+		if err := checkNameCollision(foundExport, inferenceservice); err != nil {
+			log.Error(err, "Unable to export the managed resources")
+			return err
+		}
+	}
+	if found && foundExport.Annotations[exportAnnotation] == requestID {
+		return nil
+	}
+
+	resources, err := r.managedResources(ctx, inferenceservice)
+	if err != nil {
+		log.Error(err, "Unable to fetch the managed resources")
+		return err
+	}
+	bundle, err := r.exportResources(resources)
+	if err != nil {
+		log.Error(err, "Unable to serialize the managed resources")
+		return err
+	}
+
+	desiredExport := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        inferenceServiceExportName(inferenceservice),
+			Namespace:   inferenceservice.Namespace,
+			Labels:      managedLabels(inferenceservice.Name, map[string]string{"inferenceservice-name": inferenceServiceLabelValue(inferenceservice)}),
+			Annotations: map[string]string{exportAnnotation: requestID},
+		},
+		Data: map[string]string{exportDataKey: bundle},
+	}
+	excludeFromBackup(desiredExport, r.ExcludeFromBackup)
+	if !found {
+		log.Info("Exporting the managed resources")
+		// Add .metatada.ownerReferences to the ConfigMap to be deleted by the
+		// Kubernetes garbage collector if the InferenceService is deleted
+		if err := ctrl.SetControllerReference(inferenceservice, desiredExport, r.Scheme); err != nil {
+			log.Error(err, "Unable to add OwnerReference to the export ConfigMap")
+			return err
+		}
+		if err := r.Create(ctx, desiredExport); err != nil && !apierrs.IsAlreadyExists(err) {
+			log.Error(err, "Unable to create the export ConfigMap")
+			return err
+		}
+		return nil
+	}
+
+	log.Info("Refreshing the export of the managed resources")
+	foundExport.Labels = desiredExport.Labels
+	foundExport.Annotations = desiredExport.Annotations
+	foundExport.Data = desiredExport.Data
+	if err := r.Update(ctx, foundExport); err != nil {
+		log.Error(err, "Unable to update the export ConfigMap")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+
+	predictorv1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// ingressBackendProtocolAnnotation sets the protocol the ingress controller uses to reach
+// the modelmesh service
+const ingressBackendProtocolAnnotation = "nginx.ingress.kubernetes.io/backend-protocol"
+
+// ingressManagedAnnotations are the ingress annotations set by the controller, the other
+// annotations are left to the ingress controller and the users
+var ingressManagedAnnotations = []string{ingressBackendProtocolAnnotation}
+
+// NewInferenceServiceIngress defines the desired ingress object, used instead of a route
+// on non-Openshift clusters
+func NewInferenceServiceIngress(inferenceservice *inferenceservicev1.InferenceService, enableAuth bool,
+	ingressClassName string) *networkingv1.Ingress {
+	pathType := networkingv1.PathTypePrefix
+	servicePort := int32(modelmeshServicePort)
+	var annotations map[string]string
+	if enableAuth {
+		// The auth proxy of the modelmesh service only accepts TLS connections
+		servicePort = modelmeshAuthServicePort
+		annotations = map[string]string{ingressBackendProtocolAnnotation: "HTTPS"}
+	}
+
+	return &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      inferenceservice.Name,
+			Namespace: inferenceservice.Namespace,
+			Labels: managedLabels(inferenceservice.Name, map[string]string{
+				"inferenceservice-name": inferenceServiceLabelValue(inferenceservice),
+			}),
+			Annotations: annotations,
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: &ingressClassName,
+			Rules: []networkingv1.IngressRule{{
+				IngressRuleValue: networkingv1.IngressRuleValue{
+					HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: []networkingv1.HTTPIngressPath{{
+							Path:     inferenceServiceModelPath(inferenceservice),
+							PathType: &pathType,
+							Backend: networkingv1.IngressBackend{
+								Service: &networkingv1.IngressServiceBackend{
+									Name: modelmeshServiceName,
+									Port: networkingv1.ServiceBackendPort{
+										Number: servicePort,
+									},
+								},
+							},
+						}},
+					},
+				},
+			}},
+		},
+	}
+}
+
+// CompareInferenceServiceIngresses checks if two ingresses are equal, if not return false
+func CompareInferenceServiceIngresses(i1 networkingv1.Ingress, i2 networkingv1.Ingress) bool {
+	// Two ingresses will be equal if the labels, managed annotations and spec are identical
+	for _, annotation := range ingressManagedAnnotations {
+		if i1.Annotations[annotation] != i2.Annotations[annotation] {
+			return false
+		}
+	}
+	return reflect.DeepEqual(i1.ObjectMeta.Labels, i2.ObjectMeta.Labels) &&
+		reflect.DeepEqual(i1.Spec, i2.Spec)
+}
+
+// Reconcile will manage the creation, update and deletion of the ingress returned
+// by the newIngress function
+func (r *OpenshiftInferenceServiceReconciler) reconcileIngress(inferenceservice *inferenceservicev1.InferenceService,
+	ctx context.Context, newIngress func(service *inferenceservicev1.InferenceService, enableAuth bool, ingressClassName string) *networkingv1.Ingress) error {
+	// Initialize logger format
+	log := r.Log.WithValues("inferenceservice", inferenceservice.Name, "namespace", inferenceservice.Namespace)
+
+	// The ingress is exposed according to the same serving runtime annotations as the route
+	desiredServingRuntime := &predictorv1.ServingRuntime{}
+	runtimeName, err := r.inferenceServiceRuntimeName(ctx, inferenceservice)
+	if err != nil {
+		log.Error(err, "Unable to determine the Serving Runtime")
+		return err
+	}
+	if runtimeName == "" {
+		log.Info("No Serving Runtime can serve the InferenceService " + inferenceservice.Name)
+	} else if err := r.Get(ctx, types.NamespacedName{
+		Name:      runtimeName,
+		Namespace: inferenceservice.Namespace,
+	}, desiredServingRuntime); err != nil {
+		if apierrs.IsNotFound(err) {
+			log.Info("Serving Runtime " + runtimeName + " desired by " + inferenceservice.Name + " was not found in namespace")
+		}
+	}
+	// The plain HTTP maintenance responder is reached without the auth proxy
+	inMaintenance := inferenceServiceInMaintenance(inferenceservice)
+	enableAuth := desiredServingRuntime.Annotations["enable-auth"] == "true" && !inMaintenance
+	createIngress := desiredServingRuntime.Annotations["enable-route"] == "true"
+
+	// Generate the desired ingress
+	desiredIngress := newIngress(inferenceservice, enableAuth, r.IngressClassName)
+	if inMaintenance {
+		desiredIngress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name = inferenceServiceMaintenanceServiceName(inferenceservice)
+	}
+	if host, err := inferenceServiceCustomHost(inferenceservice); err != nil {
+		// Retrying will not fix the annotation, the ingress keeps matching any host
+		log.Error(err, "Ignoring the custom host name")
+	} else if host != "" {
+		desiredIngress.Spec.Rules[0].Host = host
+	}
+	if err := r.addPropagatedLabels(ctx, desiredIngress, inferenceservice); err != nil {
+		log.Error(err, "Unable to get the labels to propagate to the ingress")
+		return err
+	}
+	excludeFromBackup(desiredIngress, r.ExcludeFromBackup)
+
+	// Create the ingress if it does not already exist
+	foundIngress := &networkingv1.Ingress{}
+	justCreated := false
+	err = r.Get(ctx, types.NamespacedName{
+		Name:      desiredIngress.Name,
+		Namespace: inferenceservice.Namespace,
+	}, foundIngress)
+	if err != nil {
+		if !createIngress {
+			log.Info("Serving runtime does not have 'enable-route' annotation set to 'True'. Skipping ingress creation")
+			return nil
+		}
+		if apierrs.IsNotFound(err) {
+			allowed, err := r.exposureAllowed(ctx, inferenceservice)
+			if err != nil {
+				log.Error(err, "Unable to check the exposed models quota")
+				return err
+			}
+			if !allowed {
+				log.Info("Exposed models quota of the namespace reached. Skipping ingress creation")
+				return nil
+			}
+			log.Info("Creating Ingress")
+			// Add .metatada.ownerReferences to the ingress to be deleted by the
+			// Kubernetes garbage collector if the predictor is deleted
+			err = ctrl.SetControllerReference(inferenceservice, desiredIngress, r.Scheme)
+			if err != nil {
+				log.Error(err, "Unable to add OwnerReference to the Ingress")
+				return err
+			}
+			// Create the ingress in the cluster
+			err = r.Create(ctx, desiredIngress)
+			if err != nil && !apierrs.IsAlreadyExists(err) {
+				log.Error(err, "Unable to create the Ingress")
+				return err
+			}
+			auditLog(AuditActionCreate, "Ingress", desiredIngress, "InferenceService "+inferenceservice.Name+" exposed")
+			justCreated = true
+		} else {
+			log.Error(err, "Unable to fetch the Ingress")
+			return err
+		}
+	}
+
+	if !justCreated && r.pausedByUsers(foundIngress, inferenceservice, log) {
+		return nil
+	}
+	if !createIngress {
+		log.Info("Serving Runtime does not have 'enable-route' annotation set to 'True'. Deleting existing ingress")
+		if err := r.Delete(ctx, foundIngress); err != nil {
+			return err
+		}
+		auditLog(AuditActionDelete, "Ingress", foundIngress, "Serving Runtime route disabled")
+		return nil
+	}
+	// Reconcile the ingress spec if it has been manually modified
+	if !justCreated && !CompareInferenceServiceIngresses(*desiredIngress, *foundIngress) {
+		log.Info("Reconciling Ingress")
+		// Retry the update operation when the ingress controller eventually
+		// updates the resource version field
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			// Get the last ingress revision
+			if err := r.Get(ctx, types.NamespacedName{
+				Name:      desiredIngress.Name,
+				Namespace: inferenceservice.Namespace,
+			}, foundIngress); err != nil {
+				return err
+			}
+			// Reconcile labels, managed annotations and spec field
+			foundIngress.Spec = desiredIngress.Spec
+			foundIngress.ObjectMeta.Labels = desiredIngress.ObjectMeta.Labels
+			for _, annotation := range ingressManagedAnnotations {
+				if value, ok := desiredIngress.Annotations[annotation]; ok {
+					if foundIngress.Annotations == nil {
+						foundIngress.Annotations = map[string]string{}
+					}
+					foundIngress.Annotations[annotation] = value
+				} else {
+					delete(foundIngress.Annotations, annotation)
+				}
+			}
+			return r.Update(ctx, foundIngress)
+		})
+		if err != nil {
+			log.Error(err, "Unable to reconcile the Ingress")
+			return err
+		}
+		auditLog(AuditActionUpdate, "Ingress", foundIngress, "Ingress reverted to the desired state")
+	}
+
+	return nil
+}
+
+// ReconcileIngress will manage the creation, update and deletion of the
+// ingress when the predictor is reconciled
+func (r *OpenshiftInferenceServiceReconciler) ReconcileIngress(
+	inferenceservice *inferenceservicev1.InferenceService, ctx context.Context) error {
+	return r.reconcileIngress(inferenceservice, ctx, NewInferenceServiceIngress)
+}
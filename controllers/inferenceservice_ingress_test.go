@@ -0,0 +1,73 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("The InferenceService Ingress", func() {
+	inferenceService := &inferenceservicev1.InferenceService{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-onnx-mnist", Namespace: WorkingNamespace},
+	}
+
+	It("Should expose the model path of the modelmesh Service", func() {
+		ingress := NewInferenceServiceIngress(inferenceService, false, "nginx")
+
+		Expect(*ingress.Spec.IngressClassName).To(Equal("nginx"))
+		Expect(ingress.Annotations).To(BeEmpty())
+		Expect(ingress.Labels).To(HaveKeyWithValue("inferenceservice-name", inferenceService.Name))
+		Expect(ingress.Spec.Rules).To(HaveLen(1))
+		Expect(ingress.Spec.Rules[0].Host).To(BeEmpty())
+
+		paths := ingress.Spec.Rules[0].HTTP.Paths
+		Expect(paths).To(HaveLen(1))
+		Expect(paths[0].Path).To(Equal("/v2/models/example-onnx-mnist"))
+		Expect(*paths[0].PathType).To(Equal(networkingv1.PathTypePrefix))
+		Expect(paths[0].Backend.Service.Name).To(Equal(modelmeshServiceName))
+		Expect(paths[0].Backend.Service.Port.Number).To(BeEquivalentTo(modelmeshServicePort))
+	})
+
+	It("Should reach the auth proxy of the modelmesh Service over TLS when auth is enabled", func() {
+		ingress := NewInferenceServiceIngress(inferenceService, true, "nginx")
+
+		Expect(ingress.Annotations).To(Equal(map[string]string{ingressBackendProtocolAnnotation: "HTTPS"}))
+		Expect(ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Port.Number).To(BeEquivalentTo(modelmeshAuthServicePort))
+	})
+
+	It("Should detect the drift of the annotations", func() {
+		desiredIngress := NewInferenceServiceIngress(inferenceService, true, "nginx")
+		foundIngress := desiredIngress.DeepCopy()
+		Expect(CompareInferenceServiceIngresses(*desiredIngress, *foundIngress)).To(BeTrue())
+
+		delete(foundIngress.Annotations, ingressBackendProtocolAnnotation)
+		Expect(CompareInferenceServiceIngresses(*desiredIngress, *foundIngress)).To(BeFalse())
+
+		By("By checking that the annotations of the ingress controller and the users are ignored")
+
+		foundIngress = desiredIngress.DeepCopy()
+		foundIngress.Annotations["nginx.ingress.kubernetes.io/proxy-body-size"] = "64m"
+		Expect(CompareInferenceServiceIngresses(*desiredIngress, *foundIngress)).To(BeTrue())
+
+		foundIngress = desiredIngress.DeepCopy()
+		foundIngress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Port.Number = modelmeshServicePort
+		Expect(CompareInferenceServiceIngresses(*desiredIngress, *foundIngress)).To(BeFalse())
+	})
+})
@@ -0,0 +1,329 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// maintenanceAnnotation takes the InferenceService out of the external traffic: its Route,
+	// or Ingress, is switched to the maintenance Service, whose endpoint is the maintenance
+	// responder of the controller. The model stays loaded in the runtime shared with the
+	// other models of the namespace, and is still reachable from inside the cluster
+	maintenanceAnnotation = "serving.opendatahub.io/maintenance"
+)
+
+// MaintenanceResponder answers the requests of the InferenceServices in external traffic
+// maintenance with a 503 and a Retry-After header. It runs in all the replicas, the
+// maintenance Services get the address of the leader as endpoint
+type MaintenanceResponder struct {
+	BindAddress string
+	// RetryAfter is the delay after which the clients are told to retry
+	RetryAfter time.Duration
+	Log        logr.Logger
+}
+
+// ServeHTTP answers 503 to all the requests
+func (m *MaintenanceResponder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(m.RetryAfter.Seconds())))
+	http.Error(w, "The model is under maintenance", http.StatusServiceUnavailable)
+}
+
+// Start serves the maintenance responses until the context is done
+func (m *MaintenanceResponder) Start(ctx context.Context) error {
+	server := &http.Server{Addr: m.BindAddress, Handler: m}
+	errs := make(chan error, 1)
+	go func() {
+		m.Log.Info("Starting maintenance responder", "address", m.BindAddress)
+		errs <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
+
+// NeedLeaderElection returns false, the endpoint of the maintenance Services may be any replica
+func (m *MaintenanceResponder) NeedLeaderElection() bool {
+	return false
+}
+
+// inferenceServiceInMaintenance returns true if the external traffic of the InferenceService
+// is switched to the maintenance responder
+func inferenceServiceInMaintenance(inferenceservice *inferenceservicev1.InferenceService) bool {
+	return inferenceservice.Annotations[maintenanceAnnotation] == "true"
+}
+
+// inferenceServiceMaintenanceServiceName returns the name of the maintenance Service
+func inferenceServiceMaintenanceServiceName(inferenceservice *inferenceservicev1.InferenceService) string {
+	return childName(inferenceservice.Name, "maintenance")
+}
+
+// NewInferenceServiceMaintenanceService defines the Service the endpoint of the
+// InferenceService is switched to during its maintenance. It has the ports of the
+// modelmesh service but no selector, its endpoint is set by the controller
+func NewInferenceServiceMaintenanceService(inferenceservice *inferenceservicev1.InferenceService) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      inferenceServiceMaintenanceServiceName(inferenceservice),
+			Namespace: inferenceservice.Namespace,
+			Labels: managedLabels(inferenceservice.Name, map[string]string{
+				"inferenceservice-name":  inferenceServiceLabelValue(inferenceservice),
+				"opendatahub.io/managed": "true",
+			}),
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "http",
+					Protocol:   corev1.ProtocolTCP,
+					Port:       modelmeshServicePort,
+					TargetPort: intstr.FromInt(modelmeshServicePort),
+				},
+				{
+					Name:       "https",
+					Protocol:   corev1.ProtocolTCP,
+					Port:       modelmeshAuthServicePort,
+					TargetPort: intstr.FromInt(modelmeshAuthServicePort),
+				},
+			},
+		},
+	}
+}
+
+// CompareInferenceServiceMaintenanceServices checks if two maintenance Services are equal,
+// if not return false
+func CompareInferenceServiceMaintenanceServices(s1 corev1.Service, s2 corev1.Service) bool {
+	// The other fields of the spec, e.g. the cluster IP, are defaulted by the API server
+	return reflect.DeepEqual(s1.ObjectMeta.Labels, s2.ObjectMeta.Labels) &&
+		reflect.DeepEqual(s1.Spec.Ports, s2.Spec.Ports) &&
+		reflect.DeepEqual(s1.Spec.Selector, s2.Spec.Selector)
+}
+
+// NewInferenceServiceMaintenanceEndpoints defines the endpoint of the maintenance Service,
+// the maintenance responder listening on the address. Both ports of the Service reach the
+// plain HTTP responder, the Route or Ingress does not reencrypt during the maintenance
+func NewInferenceServiceMaintenanceEndpoints(inferenceservice *inferenceservicev1.InferenceService, ip string,
+	port int32) *corev1.Endpoints {
+	return &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      inferenceServiceMaintenanceServiceName(inferenceservice),
+			Namespace: inferenceservice.Namespace,
+			Labels: managedLabels(inferenceservice.Name, map[string]string{
+				"inferenceservice-name":  inferenceServiceLabelValue(inferenceservice),
+				"opendatahub.io/managed": "true",
+			}),
+		},
+		Subsets: []corev1.EndpointSubset{{
+			Addresses: []corev1.EndpointAddress{{IP: ip}},
+			Ports: []corev1.EndpointPort{
+				{Name: "http", Port: port, Protocol: corev1.ProtocolTCP},
+				{Name: "https", Port: port, Protocol: corev1.ProtocolTCP},
+			},
+		}},
+	}
+}
+
+// CompareInferenceServiceMaintenanceEndpoints checks if two maintenance Endpoints are equal,
+// if not return false
+func CompareInferenceServiceMaintenanceEndpoints(e1 corev1.Endpoints, e2 corev1.Endpoints) bool {
+	return reflect.DeepEqual(e1.ObjectMeta.Labels, e2.ObjectMeta.Labels) &&
+		reflect.DeepEqual(e1.Subsets, e2.Subsets)
+}
+
+// reconcileMaintenanceEndpoints manages the endpoint of the maintenance Service, deleted when
+// the maintenance is over or the responder address is unknown. They are read
+// from the API server, caching all the Endpoints of the cluster would be expensive
+func (r *OpenshiftInferenceServiceReconciler) reconcileMaintenanceEndpoints(
+	inferenceservice *inferenceservicev1.InferenceService, ctx context.Context, log logr.Logger) error {
+	key := types.NamespacedName{Name: inferenceServiceMaintenanceServiceName(inferenceservice),
+		Namespace: inferenceservice.Namespace}
+	foundEndpoints := &corev1.Endpoints{}
+	err := r.APIReader.Get(ctx, key, foundEndpoints)
+	if err != nil && !apierrs.IsNotFound(err) {
+		log.Error(err, "Unable to fetch the maintenance Endpoints")
+		return err
+	}
+	found := err == nil
+	// The Endpoints of a Service created by the users are left untouched
+	if found && !metav1.IsControlledBy(foundEndpoints, inferenceservice) {
+		log.Info("Maintenance Endpoints not controlled by the InferenceService, leaving them unmodified")
+		return nil
+	}
+
+	if !inferenceServiceInMaintenance(inferenceservice) || r.MaintenanceResponderIP == "" {
+		if found {
+			if err := r.Delete(ctx, foundEndpoints); err != nil && !apierrs.IsNotFound(err) {
+				return err
+			}
+			auditLog(AuditActionDelete, "Endpoints", foundEndpoints, "Maintenance responder endpoint removed")
+		}
+		return nil
+	}
+
+	desiredEndpoints := NewInferenceServiceMaintenanceEndpoints(inferenceservice, r.MaintenanceResponderIP,
+		r.MaintenanceResponderPort)
+	if err := r.addPropagatedLabels(ctx, desiredEndpoints, inferenceservice); err != nil {
+		log.Error(err, "Unable to get the labels to propagate to the maintenance Endpoints")
+		return err
+	}
+	excludeFromBackup(desiredEndpoints, r.ExcludeFromBackup)
+
+	if !found {
+		log.Info("Creating maintenance Endpoints")
+		err = ctrl.SetControllerReference(inferenceservice, desiredEndpoints, r.Scheme)
+		if err != nil {
+			log.Error(err, "Unable to add OwnerReference to the maintenance Endpoints")
+			return err
+		}
+		err = r.Create(ctx, desiredEndpoints)
+		if err != nil && !apierrs.IsAlreadyExists(err) {
+			log.Error(err, "Unable to create the maintenance Endpoints")
+			return err
+		}
+		auditLog(AuditActionCreate, "Endpoints", desiredEndpoints, "Maintenance responder endpoint added")
+		return nil
+	}
+
+	// Reconcile the Endpoints when the responder moved to another replica
+	if !CompareInferenceServiceMaintenanceEndpoints(*desiredEndpoints, *foundEndpoints) {
+		log.Info("Reconciling maintenance Endpoints")
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			// Get the last Endpoints revision
+			if err := r.APIReader.Get(ctx, key, foundEndpoints); err != nil {
+				return err
+			}
+			foundEndpoints.Subsets = desiredEndpoints.Subsets
+			foundEndpoints.ObjectMeta.Labels = desiredEndpoints.ObjectMeta.Labels
+			return r.Update(ctx, foundEndpoints)
+		})
+		if err != nil {
+			log.Error(err, "Unable to reconcile the maintenance Endpoints")
+			return err
+		}
+		auditLog(AuditActionUpdate, "Endpoints", foundEndpoints, "Endpoints reverted to the desired state")
+	}
+	return nil
+}
+
+// ReconcileMaintenance will manage the creation, update and deletion of the maintenance
+// Service of the InferenceService, and of its endpoint. The endpoint of the
+// InferenceService is switched to it by the route and ingress sub-reconcilers
+func (r *OpenshiftInferenceServiceReconciler) ReconcileMaintenance(inferenceservice *inferenceservicev1.InferenceService,
+	ctx context.Context) error {
+	// Initialize logger format
+	log := r.Log.WithValues("InferenceService", inferenceservice.Name, "namespace", inferenceservice.Namespace)
+
+	desiredService := NewInferenceServiceMaintenanceService(inferenceservice)
+	foundService := &corev1.Service{}
+	err := r.Get(ctx, types.NamespacedName{Name: desiredService.Name, Namespace: desiredService.Namespace}, foundService)
+	if err != nil && !apierrs.IsNotFound(err) {
+		log.Error(err, "Unable to fetch the maintenance Service")
+		return err
+	}
+	found := err == nil
+	if found {
+		if err := checkNameCollision(foundService, inferenceservice); err != nil {
+			log.Error(err, "Unable to reconcile the maintenance Service")
+			return err
+		}
+		if r.pausedByUsers(foundService, inferenceservice, log) {
+			return nil
+		}
+	}
+
+	// Remove the Service and its endpoint once the maintenance is over
+	if !inferenceServiceInMaintenance(inferenceservice) {
+		if found {
+			if err := r.reconcileMaintenanceEndpoints(inferenceservice, ctx, log); err != nil {
+				return err
+			}
+			log.Info("External traffic maintenance is over. Deleting the maintenance Service")
+			if err := r.Delete(ctx, foundService); err != nil && !apierrs.IsNotFound(err) {
+				return err
+			}
+			auditLog(AuditActionDelete, "Service", foundService, "External traffic maintenance over")
+		}
+		return nil
+	}
+
+	if err := r.addPropagatedLabels(ctx, desiredService, inferenceservice); err != nil {
+		log.Error(err, "Unable to get the labels to propagate to the maintenance Service")
+		return err
+	}
+	excludeFromBackup(desiredService, r.ExcludeFromBackup)
+
+	if !found {
+		log.Info("Creating maintenance Service")
+		// Add .metatada.ownerReferences to the Service to be deleted by the
+		// Kubernetes garbage collector if the InferenceService is deleted
+		err = ctrl.SetControllerReference(inferenceservice, desiredService, r.Scheme)
+		if err != nil {
+			log.Error(err, "Unable to add OwnerReference to the maintenance Service")
+			return err
+		}
+		err = r.Create(ctx, desiredService)
+		if err != nil && !apierrs.IsAlreadyExists(err) {
+			log.Error(err, "Unable to create the maintenance Service")
+			return err
+		}
+		auditLog(AuditActionCreate, "Service", desiredService, "External traffic switched to maintenance")
+		return r.reconcileMaintenanceEndpoints(inferenceservice, ctx, log)
+	}
+
+	// Reconcile the Service if it has been manually modified, e.g. given a selector
+	if !CompareInferenceServiceMaintenanceServices(*desiredService, *foundService) {
+		log.Info("Reconciling maintenance Service")
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			// Get the last Service revision
+			if err := r.Get(ctx, types.NamespacedName{
+				Name:      desiredService.Name,
+				Namespace: inferenceservice.Namespace,
+			}, foundService); err != nil {
+				return err
+			}
+			foundService.Spec.Ports = desiredService.Spec.Ports
+			foundService.Spec.Selector = desiredService.Spec.Selector
+			foundService.ObjectMeta.Labels = desiredService.ObjectMeta.Labels
+			return r.Update(ctx, foundService)
+		})
+		if err != nil {
+			log.Error(err, "Unable to reconcile the maintenance Service")
+			return err
+		}
+		auditLog(AuditActionUpdate, "Service", foundService, "Service reverted to the desired state")
+	}
+	return r.reconcileMaintenanceEndpoints(inferenceservice, ctx, log)
+}
@@ -0,0 +1,56 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("The external traffic maintenance", func() {
+	inferenceService := &inferenceservicev1.InferenceService{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-onnx-mnist", Namespace: WorkingNamespace},
+	}
+
+	It("Should answer 503 with a Retry-After header", func() {
+		responder := &MaintenanceResponder{RetryAfter: 5 * time.Minute}
+		recorder := httptest.NewRecorder()
+		responder.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/v2/models/example-onnx-mnist/infer", nil))
+
+		Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(recorder.Header().Get("Retry-After")).To(Equal("300"))
+	})
+
+	It("Should reach the responder from both ports of the maintenance Service", func() {
+		service := NewInferenceServiceMaintenanceService(inferenceService)
+		endpoints := NewInferenceServiceMaintenanceEndpoints(inferenceService, "10.0.0.1", 8090)
+
+		Expect(endpoints.Name).To(Equal(service.Name))
+		Expect(endpoints.Subsets).To(HaveLen(1))
+		Expect(endpoints.Subsets[0].Addresses[0].IP).To(Equal("10.0.0.1"))
+		Expect(endpoints.Subsets[0].Ports).To(HaveLen(len(service.Spec.Ports)))
+		for i, port := range endpoints.Subsets[0].Ports {
+			Expect(port.Name).To(Equal(service.Spec.Ports[i].Name))
+			Expect(port.Port).To(BeEquivalentTo(8090))
+		}
+	})
+})
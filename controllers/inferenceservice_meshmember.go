@@ -25,19 +25,31 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/retry"
 	maistrav1 "maistra.io/api/core/v1"
-	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// NewInferenceServiceMeshMember defines the desired MeshMember object
-func NewInferenceServiceMeshMember(inferenceservice *inferenceservicev1.InferenceService) *maistrav1.ServiceMeshMember {
+const (
+	// The name MUST be default, per the maistra docs
+	serviceMeshMemberName = "default"
+)
+
+// NewInferenceServiceMeshMember defines the desired MeshMember object. The MeshMember
+// enrolls the whole namespace, so it is shared by all the InferenceServices in it
+func NewInferenceServiceMeshMember(inferenceservice *inferenceservicev1.InferenceService,
+	controlPlane types.NamespacedName) *maistrav1.ServiceMeshMember {
+	return newNamespaceMeshMember(inferenceservice.Namespace, controlPlane)
+}
+
+// newNamespaceMeshMember defines the desired MeshMember enrolling the namespace in the
+// control plane
+func newNamespaceMeshMember(namespace string, controlPlane types.NamespacedName) *maistrav1.ServiceMeshMember {
 	return &maistrav1.ServiceMeshMember{
-		TypeMeta: metav1.TypeMeta{},
-		// The name MUST be default, per the maistra docs
-		ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: inferenceservice.Namespace, Labels: map[string]string{"inferenceservice-name": inferenceservice.Name}},
+		TypeMeta:   metav1.TypeMeta{},
+		ObjectMeta: metav1.ObjectMeta{Name: serviceMeshMemberName, Namespace: namespace, Labels: managedLabels(namespace, map[string]string{"opendatahub.io/managed": "true"})},
 		Spec: maistrav1.ServiceMeshMemberSpec{
 			ControlPlaneRef: maistrav1.ServiceMeshControlPlaneRef{
-				Name:      "odh",
-				Namespace: "istio-system",
+				Name:      controlPlane.Name,
+				Namespace: controlPlane.Namespace,
 			},
 		},
 	}
@@ -46,7 +58,8 @@ func NewInferenceServiceMeshMember(inferenceservice *inferenceservicev1.Inferenc
 // CompareInferenceServiceMeshMembers checks if two MeshMembers are equal, if not return false
 func CompareInferenceServiceMeshMembers(mm1 *maistrav1.ServiceMeshMember, mm2 *maistrav1.ServiceMeshMember) bool {
 	// Two MeshMembers will be equal if the labels and spec are identical
-	return reflect.DeepEqual(mm1.ObjectMeta.Labels, mm2.ObjectMeta.Labels)
+	return reflect.DeepEqual(mm1.ObjectMeta.Labels, mm2.ObjectMeta.Labels) &&
+		reflect.DeepEqual(mm1.Spec, mm2.Spec)
 }
 
 // Reconcile will manage the creation, update and deletion of the MeshMember returned
@@ -58,6 +71,12 @@ func (r *OpenshiftInferenceServiceReconciler) reconcileMeshMember(inferenceservi
 
 	// Generate the desired ServiceMeshMember
 	desiredMeshMember := newMeshMember(inferenceservice)
+	// The MeshMember is shared by the namespace, only the namespace labels are propagated
+	if err := r.addPropagatedLabels(ctx, desiredMeshMember, nil); err != nil {
+		log.Error(err, "Unable to get the labels to propagate to the ServiceMeshMember")
+		return err
+	}
+	excludeFromBackup(desiredMeshMember, r.ExcludeFromBackup)
 
 	// Create the ServiceMeshMember if it does not already exist
 	foundMeshMember := &maistrav1.ServiceMeshMember{}
@@ -69,19 +88,15 @@ func (r *OpenshiftInferenceServiceReconciler) reconcileMeshMember(inferenceservi
 	if err != nil {
 		if apierrs.IsNotFound(err) {
 			log.Info("Creating ServiceMeshMember")
-			// Add .metatada.ownerReferences to the MeshMember to be deleted by the
-			// Kubernetes garbage collector if the Predictor is deleted
-			err = ctrl.SetControllerReference(inferenceservice, desiredMeshMember, r.Scheme)
-			if err != nil {
-				log.Error(err, "Unable to add OwnerReference to the MeshMember")
-				return err
-			}
+			// No owner reference is set since the MeshMember is shared by the namespace,
+			// it is removed by cleanupMeshMember once the last InferenceService is deleted
 			// Create the ServiceMeshMember in the Openshift cluster
 			err = r.Create(ctx, desiredMeshMember)
 			if err != nil && !apierrs.IsAlreadyExists(err) {
 				log.Error(err, "Unable to create the ServiceMeshMember")
 				return err
 			}
+			auditLog(AuditActionCreate, "ServiceMeshMember", desiredMeshMember, "Namespace enrolled in the Service Mesh")
 			justCreated = true
 		} else {
 			log.Error(err, "Unable to fetch the ServiceMeshMember")
@@ -89,7 +104,15 @@ func (r *OpenshiftInferenceServiceReconciler) reconcileMeshMember(inferenceservi
 		}
 	}
 
+	// Leave alone the MeshMembers created by the users, and the ones they took over
+	if !justCreated && !checkOpenDataHubLabel(foundMeshMember.Labels) {
+		log.Info("ServiceMeshMember not created by the controller, leaving it untouched")
+		return nil
+	}
 	// Reconcile the MeshMember spec if it has been manually modified
+	if !justCreated && r.pausedByUsers(foundMeshMember, inferenceservice, log) {
+		return nil
+	}
 	if !justCreated && !CompareInferenceServiceMeshMembers(desiredMeshMember, foundMeshMember) {
 		log.Info("Reconciling ServiceMeshMember")
 		// Retry the update operation when the ingress controller eventually
@@ -111,6 +134,7 @@ func (r *OpenshiftInferenceServiceReconciler) reconcileMeshMember(inferenceservi
 			log.Error(err, "Unable to reconcile the ServiceMeshMember")
 			return err
 		}
+		auditLog(AuditActionUpdate, "ServiceMeshMember", foundMeshMember, "ServiceMeshMember reverted to the desired state")
 	}
 
 	return nil
@@ -120,5 +144,53 @@ func (r *OpenshiftInferenceServiceReconciler) reconcileMeshMember(inferenceservi
 // MeshMember when the Predictor is reconciled
 func (r *OpenshiftInferenceServiceReconciler) ReconcileMeshMember(
 	inferenceservice *inferenceservicev1.InferenceService, ctx context.Context) error {
-	return r.reconcileMeshMember(inferenceservice, ctx, NewInferenceServiceMeshMember)
+	controlPlane := meshControlPlaneOrDefault(r.MeshControlPlane)
+	return r.reconcileMeshMember(inferenceservice, ctx,
+		func(inferenceservice *inferenceservicev1.InferenceService) *maistrav1.ServiceMeshMember {
+			return NewInferenceServiceMeshMember(inferenceservice, controlPlane)
+		})
+}
+
+// cleanupMeshMember removes the MeshMember managed by the controller from the namespace
+// when no InferenceService remains in it
+func (r *OpenshiftInferenceServiceReconciler) cleanupMeshMember(ctx context.Context, namespace string) error {
+	// Initialize logger format
+	log := r.Log.WithValues("namespace", namespace)
+
+	inferenceServicesList := &inferenceservicev1.InferenceServiceList{}
+	err := r.List(ctx, inferenceServicesList, client.InNamespace(namespace))
+	if err != nil {
+		log.Error(err, "Unable to list the InferenceServices")
+		return err
+	}
+	if len(inferenceServicesList.Items) > 0 {
+		return nil
+	}
+
+	foundMeshMember := &maistrav1.ServiceMeshMember{}
+	err = r.Get(ctx, types.NamespacedName{
+		Name:      serviceMeshMemberName,
+		Namespace: namespace,
+	}, foundMeshMember)
+	if err != nil {
+		if apierrs.IsNotFound(err) {
+			return nil
+		}
+		log.Error(err, "Unable to fetch the ServiceMeshMember")
+		return err
+	}
+
+	// Leave alone the MeshMembers created or taken over by the users
+	if !checkOpenDataHubLabel(foundMeshMember.Labels) || r.pausedByUsers(foundMeshMember, nil, log) {
+		return nil
+	}
+
+	log.Info("No InferenceServices left in the namespace, deleting ServiceMeshMember")
+	err = r.Delete(ctx, foundMeshMember)
+	if err != nil && !apierrs.IsNotFound(err) {
+		log.Error(err, "Unable to delete the ServiceMeshMember")
+		return err
+	}
+	auditLog(AuditActionDelete, "ServiceMeshMember", foundMeshMember, "No InferenceServices left in the namespace")
+	return nil
 }
@@ -0,0 +1,82 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sync"
+
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	modelReady = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "odh_model_ready",
+		Help: "Whether the InferenceService has the Ready condition (1) or not (0)",
+	}, []string{"namespace", "model", "runtime"})
+
+	// modelReadyRuntimes holds the runtime label of the series of each InferenceService, to
+	// delete the series once the InferenceService is deleted or moved to another runtime
+	modelReadyRuntimes sync.Map
+)
+
+func init() {
+	metrics.Registry.MustRegister(modelReady)
+}
+
+// inferenceServiceReady returns true if the InferenceService has the Ready condition
+func inferenceServiceReady(inferenceservice *inferenceservicev1.InferenceService) bool {
+	for _, condition := range inferenceservice.Status.Conditions {
+		if condition.Type == "Ready" {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// recordModelReadiness sets the odh_model_ready gauge of the InferenceService
+func (r *OpenshiftInferenceServiceReconciler) recordModelReadiness(ctx context.Context,
+	inferenceservice *inferenceservicev1.InferenceService) {
+	runtimeName, err := r.inferenceServiceRuntimeName(ctx, inferenceservice)
+	if err != nil {
+		// The series keeps its last value until the runtime can be determined
+		r.Log.Error(err, "Unable to determine the Serving Runtime of the readiness metric",
+			"InferenceService", inferenceservice.Name, "namespace", inferenceservice.Namespace)
+		return
+	}
+	key := types.NamespacedName{Name: inferenceservice.Name, Namespace: inferenceservice.Namespace}
+	if previous, ok := modelReadyRuntimes.Load(key); ok && previous.(string) != runtimeName {
+		modelReady.DeleteLabelValues(key.Namespace, key.Name, previous.(string))
+	}
+	modelReadyRuntimes.Store(key, runtimeName)
+
+	value := 0.0
+	if inferenceServiceReady(inferenceservice) {
+		value = 1
+	}
+	modelReady.WithLabelValues(key.Namespace, key.Name, runtimeName).Set(value)
+}
+
+// forgetModelReadiness deletes the odh_model_ready series of the deleted InferenceService
+func forgetModelReadiness(key types.NamespacedName) {
+	if previous, ok := modelReadyRuntimes.LoadAndDelete(key); ok {
+		modelReady.DeleteLabelValues(key.Namespace, key.Name, previous.(string))
+	}
+}
@@ -0,0 +1,234 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// sloLatencyP95Annotation sets the p95 latency objective of the model, e.g. "2s"
+	sloLatencyP95Annotation = "serving.opendatahub.io/slo-latency-p95"
+	// sloAvailabilityAnnotation sets the availability objective of the model in percent, e.g. "99.9"
+	sloAvailabilityAnnotation = "serving.opendatahub.io/slo-availability"
+	// DefaultSLOWindow is the rate window of the recording rules when none is configured
+	DefaultSLOWindow = "5m"
+
+	// modelmeshRequestMetric is the histogram of the inference requests served by modelmesh
+	modelmeshRequestMetric = "modelmesh_api_request_milliseconds"
+)
+
+// inferenceServiceSLORuleName returns the name of the PrometheusRule of the InferenceService
+func inferenceServiceSLORuleName(inferenceservice *inferenceservicev1.InferenceService) string {
+	return childName(inferenceservice.Name, "slo")
+}
+
+// NewInferenceServiceSLORule defines the desired PrometheusRule recording the availability
+// ratio and p95 latency of the model over the window, along with the objectives set in the
+// InferenceService annotations. It returns nil when no objective is set
+func NewInferenceServiceSLORule(inferenceservice *inferenceservicev1.InferenceService, window string) (*monitoringv1.PrometheusRule, error) {
+	latencyObjective, latencySet := inferenceservice.Annotations[sloLatencyP95Annotation]
+	availabilityObjective, availabilitySet := inferenceservice.Annotations[sloAvailabilityAnnotation]
+	if !latencySet && !availabilitySet {
+		return nil, nil
+	}
+
+	labels := map[string]string{
+		"namespace":        inferenceservice.Namespace,
+		"inferenceservice": inferenceservice.Name,
+	}
+	selector := fmt.Sprintf(`namespace="%s",modelId="%s"`, inferenceservice.Namespace, inferenceservice.Name)
+	rules := []monitoringv1.Rule{
+		{
+			Record: "odh:model_request_availability:ratio_rate" + window,
+			Expr: intstr.FromString(fmt.Sprintf(`sum(rate(%s_count{%s,code="OK"}[%s])) / sum(rate(%s_count{%s}[%s]))`,
+				modelmeshRequestMetric, selector, window, modelmeshRequestMetric, selector, window)),
+			Labels: labels,
+		},
+		{
+			Record: "odh:model_request_latency_seconds:p95_rate" + window,
+			Expr: intstr.FromString(fmt.Sprintf(`histogram_quantile(0.95, sum by (le) (rate(%s_bucket{%s}[%s]))) / 1000`,
+				modelmeshRequestMetric, selector, window)),
+			Labels: labels,
+		},
+	}
+
+	if latencySet {
+		latency, err := time.ParseDuration(latencyObjective)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation %q: %w", sloLatencyP95Annotation, latencyObjective, err)
+		}
+		rules = append(rules, monitoringv1.Rule{
+			Record: "odh:model_request_latency_seconds:p95_objective",
+			Expr:   intstr.FromString(fmt.Sprintf("vector(%g)", latency.Seconds())),
+			Labels: labels,
+		})
+	}
+	if availabilitySet {
+		availability, err := strconv.ParseFloat(availabilityObjective, 64)
+		if err != nil || availability <= 0 || availability > 100 {
+			return nil, fmt.Errorf("invalid %s annotation %q, expected a percentage", sloAvailabilityAnnotation, availabilityObjective)
+		}
+		rules = append(rules, monitoringv1.Rule{
+			Record: "odh:model_request_availability:objective",
+			Expr:   intstr.FromString(fmt.Sprintf("vector(%g)", availability/100)),
+			Labels: labels,
+		})
+	}
+
+	return &monitoringv1.PrometheusRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      inferenceServiceSLORuleName(inferenceservice),
+			Namespace: inferenceservice.Namespace,
+			Labels: managedLabels(inferenceservice.Name, map[string]string{
+				"inferenceservice-name":  inferenceServiceLabelValue(inferenceservice),
+				"opendatahub.io/managed": "true",
+			}),
+		},
+		Spec: monitoringv1.PrometheusRuleSpec{
+			Groups: []monitoringv1.RuleGroup{{
+				Name:  inferenceservice.Name + ".slo.rules",
+				Rules: rules,
+			}},
+		},
+	}, nil
+}
+
+// CompareInferenceServiceSLORules checks if two PrometheusRules are equal, if not return false
+func CompareInferenceServiceSLORules(pr1 monitoringv1.PrometheusRule, pr2 monitoringv1.PrometheusRule) bool {
+	// Two PrometheusRules will be equal if the labels and spec are identical
+	return reflect.DeepEqual(pr1.ObjectMeta.Labels, pr2.ObjectMeta.Labels) &&
+		reflect.DeepEqual(pr1.Spec, pr2.Spec)
+}
+
+// ReconcileSLORules will manage the creation, update and deletion of the PrometheusRule
+// recording the SLO indicators of the model when the InferenceService is reconciled
+func (r *OpenshiftInferenceServiceReconciler) ReconcileSLORules(inferenceservice *inferenceservicev1.InferenceService,
+	ctx context.Context) error {
+	// Initialize logger format
+	log := r.Log.WithValues("InferenceService", inferenceservice.Name, "namespace", inferenceservice.Namespace)
+
+	window := r.SLOWindow
+	if window == "" {
+		window = DefaultSLOWindow
+	}
+	desiredRule, err := NewInferenceServiceSLORule(inferenceservice, window)
+	if err != nil {
+		// Retrying will not fix the annotation, wait for the InferenceService to be updated
+		log.Error(err, "Unable to generate the SLO recording rules")
+		return nil
+	}
+
+	if desiredRule != nil {
+		if err := r.addPropagatedLabels(ctx, desiredRule, inferenceservice); err != nil {
+			log.Error(err, "Unable to get the labels to propagate to the PrometheusRule")
+			return err
+		}
+		excludeFromBackup(desiredRule, r.ExcludeFromBackup)
+	}
+
+	foundRule := &monitoringv1.PrometheusRule{}
+	err = r.Get(ctx, types.NamespacedName{
+		Name:      inferenceServiceSLORuleName(inferenceservice),
+		Namespace: inferenceservice.Namespace,
+	}, foundRule)
+	if err != nil && !apierrs.IsNotFound(err) {
+		log.Error(err, "Unable to fetch the PrometheusRule")
+		return err
+	}
+	found := err == nil
+	if found {
+		if err := checkNameCollision(foundRule, inferenceservice); err != nil {
+			log.Error(err, "Unable to reconcile the PrometheusRule")
+			return err
+		}
+		if r.pausedByUsers(foundRule, inferenceservice, log) {
+			return nil
+		}
+	}
+
+	// Remove the rules once the objectives are removed from the InferenceService
+	if desiredRule == nil {
+		if found {
+			log.Info("InferenceService has no SLO annotation. Deleting existing PrometheusRule")
+			if err := r.Delete(ctx, foundRule); err != nil && !apierrs.IsNotFound(err) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Remove the rules created before the name was shortened
+	if err := r.deleteLegacyResource(ctx, desiredRule, inferenceservice.Name+"-slo", inferenceservice); err != nil {
+		log.Error(err, "Unable to delete the PrometheusRule with the legacy name")
+		return err
+	}
+
+	if !found {
+		log.Info("Creating PrometheusRule")
+		// Add .metatada.ownerReferences to the PrometheusRule to be deleted by the
+		// Kubernetes garbage collector if the InferenceService is deleted
+		err = ctrl.SetControllerReference(inferenceservice, desiredRule, r.Scheme)
+		if err != nil {
+			log.Error(err, "Unable to add OwnerReference to the PrometheusRule")
+			return err
+		}
+		err = r.Create(ctx, desiredRule)
+		if err != nil && !apierrs.IsAlreadyExists(err) {
+			log.Error(err, "Unable to create the PrometheusRule")
+			return err
+		}
+		return nil
+	}
+
+	// Reconcile the PrometheusRule spec if it has been manually modified or the
+	// objectives have changed
+	if !CompareInferenceServiceSLORules(*desiredRule, *foundRule) {
+		log.Info("Reconciling PrometheusRule")
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			// Get the last PrometheusRule revision
+			if err := r.Get(ctx, types.NamespacedName{
+				Name:      desiredRule.Name,
+				Namespace: inferenceservice.Namespace,
+			}, foundRule); err != nil {
+				return err
+			}
+			// Reconcile labels and spec field
+			foundRule.Spec = desiredRule.Spec
+			foundRule.ObjectMeta.Labels = desiredRule.ObjectMeta.Labels
+			return r.Update(ctx, foundRule)
+		})
+		if err != nil {
+			log.Error(err, "Unable to reconcile the PrometheusRule")
+			return err
+		}
+	}
+
+	return nil
+}
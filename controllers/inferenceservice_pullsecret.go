@@ -0,0 +1,326 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	predictorv1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// pullSecretAnnotation marks the ServingRuntimes whose images are pulled from the private
+	// registry of the RuntimePullSecret when "true"
+	pullSecretAnnotation = "serving.opendatahub.io/use-pull-secret"
+)
+
+// NewRuntimePullSecret defines the copy of the pull Secret in the namespace of the
+// InferenceService. The Secret is shared by the InferenceServices of the namespace
+func NewRuntimePullSecret(source *corev1.Secret, namespace string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      source.Name,
+			Namespace: namespace,
+			Labels:    managedLabels(namespace, map[string]string{"opendatahub.io/managed": "true"}),
+		},
+		Type: source.Type,
+		Data: source.Data,
+	}
+}
+
+// CompareRuntimePullSecrets checks if two pull Secrets are equal, if not return false
+func CompareRuntimePullSecrets(s1 corev1.Secret, s2 corev1.Secret) bool {
+	// The type of a Secret is immutable
+	return reflect.DeepEqual(s1.ObjectMeta.Labels, s2.ObjectMeta.Labels) && reflect.DeepEqual(s1.Data, s2.Data)
+}
+
+// linkPullSecret adds the pull Secret to the image pull secrets of the service account,
+// returns true if the service account was modified
+func linkPullSecret(serviceAccount *corev1.ServiceAccount, name string) bool {
+	for _, pullSecret := range serviceAccount.ImagePullSecrets {
+		if pullSecret.Name == name {
+			return false
+		}
+	}
+	serviceAccount.ImagePullSecrets = append(serviceAccount.ImagePullSecrets, corev1.LocalObjectReference{Name: name})
+	return true
+}
+
+// ReconcilePullSecret will replicate the RuntimePullSecret in the namespace of the
+// InferenceService when its serving runtime has the 'serving.opendatahub.io/use-pull-secret'
+// annotation set to 'true', and link it to the service account of the runtime pods
+func (r *OpenshiftInferenceServiceReconciler) ReconcilePullSecret(inferenceservice *inferenceservicev1.InferenceService,
+	ctx context.Context) error {
+	// Initialize logger format
+	log := r.Log.WithValues("inferenceservice", inferenceservice.Name, "namespace", inferenceservice.Namespace)
+
+	runtimeName := explicitRuntimeName(inferenceservice)
+	if runtimeName == "" {
+		log.Info("Serving runtime selected by ModelMesh, leaving it unmodified")
+		return nil
+	}
+	servingRuntime := &predictorv1.ServingRuntime{}
+	err := r.Get(ctx, types.NamespacedName{Name: runtimeName, Namespace: inferenceservice.Namespace}, servingRuntime)
+	if err != nil {
+		if apierrs.IsNotFound(err) {
+			return nil
+		}
+		log.Error(err, "Unable to fetch the Serving Runtime")
+		return err
+	}
+	if servingRuntime.Annotations[pullSecretAnnotation] != "true" {
+		return nil
+	}
+
+	sourceSecret := &corev1.Secret{}
+	err = r.Get(ctx, r.RuntimePullSecret, sourceSecret)
+	if apierrs.IsNotFound(err) {
+		log.Info("Pull Secret " + r.RuntimePullSecret.String() + " was not found")
+		return nil
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the pull Secret "+r.RuntimePullSecret.String())
+		return err
+	}
+
+	// The Secret is not replicated in its own namespace
+	if inferenceservice.Namespace != r.RuntimePullSecret.Namespace {
+		if err := r.reconcilePullSecretCopy(ctx, inferenceservice, sourceSecret); err != nil {
+			return err
+		}
+	}
+
+	serviceAccountKey := types.NamespacedName{Name: modelMeshServiceAccountName, Namespace: inferenceservice.Namespace}
+	serviceAccount := &corev1.ServiceAccount{}
+	err = r.Get(ctx, serviceAccountKey, serviceAccount)
+	if err != nil {
+		log.Error(err, "Unable to fetch the Service Account of the Serving Runtime")
+		return err
+	}
+	if !linkPullSecret(serviceAccount.DeepCopy(), sourceSecret.Name) {
+		return nil
+	}
+	log.Info("Linking the pull Secret to the Service Account " + serviceAccount.Name)
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// Get the last service account revision
+		if err := r.Get(ctx, serviceAccountKey, serviceAccount); err != nil {
+			return err
+		}
+		if !linkPullSecret(serviceAccount, sourceSecret.Name) {
+			return nil
+		}
+		return r.Update(ctx, serviceAccount)
+	})
+	if err != nil {
+		log.Error(err, "Unable to link the pull Secret to the Service Account")
+		return err
+	}
+	auditLog(AuditActionUpdate, "ServiceAccount", serviceAccount, "Pull Secret "+sourceSecret.Name+" linked")
+	return nil
+}
+
+// reconcilePullSecretCopy creates, or updates, the copy of the pull Secret in the namespace
+// of the InferenceService
+func (r *OpenshiftInferenceServiceReconciler) reconcilePullSecretCopy(ctx context.Context,
+	inferenceservice *inferenceservicev1.InferenceService, sourceSecret *corev1.Secret) error {
+	// Initialize logger format
+	log := r.Log.WithValues("inferenceservice", inferenceservice.Name, "namespace", inferenceservice.Namespace)
+
+	desiredSecret := NewRuntimePullSecret(sourceSecret, inferenceservice.Namespace)
+	// The Secret is shared by the namespace, only the namespace labels are propagated and it
+	// has no owner, it is deleted by cleanupPullSecret with the last InferenceService using it
+	if err := r.addPropagatedLabels(ctx, desiredSecret, nil); err != nil {
+		log.Error(err, "Unable to get the labels to propagate to the pull Secret")
+		return err
+	}
+	excludeFromBackup(desiredSecret, r.ExcludeFromBackup)
+
+	foundSecret := &corev1.Secret{}
+	secretKey := types.NamespacedName{Name: desiredSecret.Name, Namespace: desiredSecret.Namespace}
+	err := r.Get(ctx, secretKey, foundSecret)
+	if apierrs.IsNotFound(err) {
+		log.Info("Creating pull Secret")
+		if err := r.Create(ctx, desiredSecret); err != nil && !apierrs.IsAlreadyExists(err) {
+			log.Error(err, "Unable to create the pull Secret")
+			return err
+		}
+		auditLog(AuditActionCreate, "Secret", desiredSecret, "Pull Secret replicated from "+r.RuntimePullSecret.String())
+		return nil
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the pull Secret")
+		return err
+	}
+
+	// A Secret of the users with the same name is not overwritten
+	if foundSecret.Labels[managedByLabel] != controllerName {
+		log.Error(fmt.Errorf("the Secret %s is not managed by the controller", foundSecret.Name),
+			"Unable to replicate the pull Secret")
+		return nil
+	}
+	if r.pausedByUsers(foundSecret, inferenceservice, log) {
+		return nil
+	}
+
+	// Reconcile the Secret if the source has been updated or the copy manually modified. The
+	// copies created by previous versions were owned by an InferenceService, the owner is
+	// removed for the copy not to be deleted with it
+	if !CompareRuntimePullSecrets(*desiredSecret, *foundSecret) || len(foundSecret.OwnerReferences) > 0 {
+		log.Info("Reconciling pull Secret")
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			// Get the last Secret revision
+			if err := r.Get(ctx, secretKey, foundSecret); err != nil {
+				return err
+			}
+			foundSecret.Data = desiredSecret.Data
+			foundSecret.ObjectMeta.Labels = desiredSecret.ObjectMeta.Labels
+			foundSecret.OwnerReferences = nil
+			return r.Update(ctx, foundSecret)
+		})
+		if err != nil {
+			log.Error(err, "Unable to reconcile the pull Secret")
+			return err
+		}
+		auditLog(AuditActionUpdate, "Secret", foundSecret, "Pull Secret synchronized with "+r.RuntimePullSecret.String())
+	}
+	return nil
+}
+
+// pullSecretUsed returns true if one of the InferenceServices of the namespace is deployed
+// on a serving runtime using the pull Secret
+func (r *OpenshiftInferenceServiceReconciler) pullSecretUsed(ctx context.Context, namespace string) (bool, error) {
+	inferenceServicesList := &inferenceservicev1.InferenceServiceList{}
+	if err := r.List(ctx, inferenceServicesList, client.InNamespace(namespace)); err != nil {
+		return false, err
+	}
+	for i := range inferenceServicesList.Items {
+		runtimeName := explicitRuntimeName(&inferenceServicesList.Items[i])
+		if runtimeName == "" {
+			continue
+		}
+		servingRuntime := &predictorv1.ServingRuntime{}
+		err := r.Get(ctx, types.NamespacedName{Name: runtimeName, Namespace: namespace}, servingRuntime)
+		if apierrs.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return false, err
+		}
+		if servingRuntime.Annotations[pullSecretAnnotation] == "true" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// cleanupPullSecret removes the copy of the pull Secret managed by the controller, and its
+// link from the service account of the runtime pods, when no InferenceService of the
+// namespace uses it anymore
+func (r *OpenshiftInferenceServiceReconciler) cleanupPullSecret(ctx context.Context, namespace string) error {
+	// Initialize logger format
+	log := r.Log.WithValues("namespace", namespace)
+
+	used, err := r.pullSecretUsed(ctx, namespace)
+	if err != nil {
+		log.Error(err, "Unable to determine if the pull Secret is used")
+		return err
+	}
+	if used {
+		return nil
+	}
+
+	serviceAccountKey := types.NamespacedName{Name: modelMeshServiceAccountName, Namespace: namespace}
+	serviceAccount := &corev1.ServiceAccount{}
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// Get the last service account revision
+		if err := r.Get(ctx, serviceAccountKey, serviceAccount); err != nil {
+			return err
+		}
+		if !unlinkPullSecret(serviceAccount, r.RuntimePullSecret.Name) {
+			return nil
+		}
+		log.Info("Unlinking the pull Secret from the Service Account " + serviceAccount.Name)
+		return r.Update(ctx, serviceAccount)
+	})
+	if err != nil && !apierrs.IsNotFound(err) {
+		log.Error(err, "Unable to unlink the pull Secret from the Service Account")
+		return err
+	}
+
+	// The RuntimePullSecret itself is not a copy
+	if namespace == r.RuntimePullSecret.Namespace {
+		return nil
+	}
+	foundSecret := &corev1.Secret{}
+	err = r.Get(ctx, types.NamespacedName{Name: r.RuntimePullSecret.Name, Namespace: namespace}, foundSecret)
+	if apierrs.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the pull Secret")
+		return err
+	}
+	if foundSecret.Labels[managedByLabel] != controllerName || r.pausedByUsers(foundSecret, nil, log) {
+		return nil
+	}
+	log.Info("No InferenceServices using the pull Secret left in the namespace, deleting it")
+	if err := r.Delete(ctx, foundSecret); err != nil && !apierrs.IsNotFound(err) {
+		log.Error(err, "Unable to delete the pull Secret")
+		return err
+	}
+	auditLog(AuditActionDelete, "Secret", foundSecret, "No InferenceServices using the pull Secret left in the namespace")
+	return nil
+}
+
+// unlinkPullSecret removes the pull Secret from the image pull secrets of the service
+// account, returns true if the service account was modified
+func unlinkPullSecret(serviceAccount *corev1.ServiceAccount, name string) bool {
+	for i, pullSecret := range serviceAccount.ImagePullSecrets {
+		if pullSecret.Name == name {
+			serviceAccount.ImagePullSecrets = append(serviceAccount.ImagePullSecrets[:i],
+				serviceAccount.ImagePullSecrets[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// requeuePullSecretInferenceServices returns the reconcile requests of all the
+// InferenceServices when the RuntimePullSecret is modified, or of the InferenceServices of
+// the namespace when its copy is modified
+func (r *OpenshiftInferenceServiceReconciler) requeuePullSecretInferenceServices(o client.Object) []reconcile.Request {
+	if o.GetName() != r.RuntimePullSecret.Name {
+		return []reconcile.Request{}
+	}
+	if o.GetNamespace() != r.RuntimePullSecret.Namespace {
+		if !checkOpenDataHubLabel(o.GetLabels()) {
+			return []reconcile.Request{}
+		}
+		return r.requeueNamespaceInferenceServices(o.GetNamespace())
+	}
+	inferenceServicesList := &inferenceservicev1.InferenceServiceList{}
+	if err := r.List(context.TODO(), inferenceServicesList); err != nil {
+		r.Log.Info("Error getting list of inference services for pull secret " + o.GetName())
+		return []reconcile.Request{}
+	}
+	return inferenceServicesRequests(inferenceServicesList)
+}
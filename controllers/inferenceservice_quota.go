@@ -0,0 +1,153 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// exposedModelsQuotaAnnotation sets the maximum number of InferenceServices of the
+	// namespace exposed outside the cluster with a Route or an Ingress
+	exposedModelsQuotaAnnotation = "serving.opendatahub.io/max-exposed-models"
+	// exposureQuotaExceededReason is the reason of the events of the InferenceServices not
+	// exposed because of the quota
+	exposureQuotaExceededReason = "ExposureQuotaExceeded"
+)
+
+// exposedModelsQuota returns the quota of exposed InferenceServices set on the namespace,
+// or -1 when there is none
+func exposedModelsQuota(ns *corev1.Namespace) (int, error) {
+	value, ok := ns.Annotations[exposedModelsQuotaAnnotation]
+	if !ok {
+		return -1, nil
+	}
+	quota, err := strconv.Atoi(value)
+	if err != nil || quota < 0 {
+		return -1, fmt.Errorf("invalid %s annotation %q, expected a non-negative integer", exposedModelsQuotaAnnotation, value)
+	}
+	return quota, nil
+}
+
+// exposedInferenceServices returns the label values of the InferenceServices of the namespace
+// exposed by the Routes, or Ingresses, created by the controller. They are listed from the
+// API server, the cache may not hold yet the ones just created by the previous reconciles
+func (r *OpenshiftInferenceServiceReconciler) exposedInferenceServices(ctx context.Context,
+	namespace string) (map[string]bool, error) {
+	opts := []client.ListOption{client.InNamespace(namespace), client.HasLabels{"inferenceservice-name"}}
+	exposed := map[string]bool{}
+	if r.IngressClassName != "" {
+		ingresses := &networkingv1.IngressList{}
+		if err := r.APIReader.List(ctx, ingresses, opts...); err != nil {
+			return nil, err
+		}
+		for _, ingress := range ingresses.Items {
+			exposed[ingress.Labels["inferenceservice-name"]] = true
+		}
+		return exposed, nil
+	}
+	routes := &routev1.RouteList{}
+	if err := r.APIReader.List(ctx, routes, opts...); err != nil {
+		return nil, err
+	}
+	for _, route := range routes.Items {
+		exposed[route.Labels["inferenceservice-name"]] = true
+	}
+	return exposed, nil
+}
+
+// exposureAllowed returns true if the InferenceService can be exposed without exceeding the
+// quota of its namespace, otherwise a warning event is recorded on the InferenceService. Its
+// status is left to ModelMesh, which replaces the conditions when it updates it. The
+// InferenceServices already exposed are kept when the quota is lowered
+func (r *OpenshiftInferenceServiceReconciler) exposureAllowed(ctx context.Context,
+	inferenceservice *inferenceservicev1.InferenceService) (bool, error) {
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: inferenceservice.Namespace}, ns); err != nil {
+		return false, err
+	}
+	quota, err := exposedModelsQuota(ns)
+	if err != nil {
+		// Retrying will not fix the annotation, the namespace is considered without quota
+		r.Log.Error(err, "Ignoring the exposed models quota", "namespace", ns.Name)
+		quota = -1
+	}
+	if quota < 0 {
+		return true, nil
+	}
+
+	exposed, err := r.exposedInferenceServices(ctx, inferenceservice.Namespace)
+	if err != nil {
+		return false, err
+	}
+	if exposed[inferenceServiceLabelValue(inferenceservice)] || len(exposed) < quota {
+		return true, nil
+	}
+	r.Recorder.Eventf(inferenceservice, corev1.EventTypeWarning, exposureQuotaExceededReason,
+		"The namespace already exposes %d of its %d allowed models, the InferenceService is not exposed",
+		len(exposed), quota)
+	return false, nil
+}
+
+// exposureQuotaChanged filters the events changing the quota of a namespace
+func exposureQuotaChanged() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return false
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return false
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return false
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return e.ObjectOld.GetAnnotations()[exposedModelsQuotaAnnotation] !=
+				e.ObjectNew.GetAnnotations()[exposedModelsQuotaAnnotation]
+		},
+	}
+}
+
+// exposureReleased filters the deletion events of the Routes, or Ingresses, releasing a
+// slot of the quota of their namespace
+func exposureReleased() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return false
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			_, ok := e.Object.GetLabels()["inferenceservice-name"]
+			return ok
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return false
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return false
+		},
+	}
+}
@@ -17,6 +17,9 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"strings"
+
 	predictorv1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
 	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
 	"reflect"
@@ -26,6 +29,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/utils/pointer"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -35,18 +39,68 @@ const (
 	modelmeshServiceName     = "modelmesh-serving"
 	modelmeshAuthServicePort = 8443
 	modelmeshServicePort     = 8008
+	// routeRewriteTargetAnnotation makes the Openshift router replace the path of the route
+	// with its value before forwarding the requests
+	routeRewriteTargetAnnotation = "haproxy.router.openshift.io/rewrite-target"
+	// customHostnameAnnotation sets a stable host name of the InferenceService endpoint,
+	// instead of the one generated from its name and namespace
+	customHostnameAnnotation = "serving.opendatahub.io/custom-hostname"
 )
 
+// routeManagedAnnotations are the route annotations set by the controller, the other
+// annotations are left to the router and the users
+var routeManagedAnnotations = []string{routeRewriteTargetAnnotation, routeHSTSAnnotation, customHostnameAnnotation}
+
+// inferenceServiceCustomHost returns the custom host name of the InferenceService, or an
+// empty string when it has none
+func inferenceServiceCustomHost(inferenceservice *inferenceservicev1.InferenceService) (string, error) {
+	host := inferenceservice.Annotations[customHostnameAnnotation]
+	if host == "" {
+		return "", nil
+	}
+	if errs := validation.IsDNS1123Subdomain(host); len(errs) > 0 {
+		return "", fmt.Errorf("invalid %s annotation %q: %s", customHostnameAnnotation, host, strings.Join(errs, ", "))
+	}
+	return host, nil
+}
+
+// setRouteCustomHost exposes the route on the custom host, the annotation keeps track of
+// the custom host to regenerate the route once it is removed
+func setRouteCustomHost(route *routev1.Route, host string) {
+	if route.Annotations == nil {
+		route.Annotations = map[string]string{}
+	}
+	route.Annotations[customHostnameAnnotation] = host
+	route.Spec.Host = host
+}
+
+// inferenceServiceModelPath returns the path of the model REST endpoints
+func inferenceServiceModelPath(inferenceservice *inferenceservicev1.InferenceService) string {
+	return "/v2/models/" + inferenceservice.Name
+}
+
+// inferenceServiceRouteName returns the name of the route, shortened so that the
+// "<name>-<namespace>" host label generated by the router fits in a DNS label
+func inferenceServiceRouteName(inferenceservice *inferenceservicev1.InferenceService) string {
+	return truncateName(inferenceservice.Name, maxNameLength-len(inferenceservice.Namespace)-1)
+}
+
+// generatedHostFits returns false if the namespace is too long for the "<name>-<namespace>"
+// host label generated by the router to fit in a DNS label, whatever the route name
+func generatedHostFits(namespace string) bool {
+	return len(namespace)+2 <= maxNameLength
+}
+
 // NewInferenceServiceRoute defines the desired route object
 func NewInferenceServiceRoute(inferenceservice *inferenceservicev1.InferenceService, enableAuth bool) *routev1.Route {
 
 	finalRoute := &routev1.Route{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      inferenceservice.Name,
+			Name:      inferenceServiceRouteName(inferenceservice),
 			Namespace: inferenceservice.Namespace,
-			Labels: map[string]string{
-				"inferenceservice-name": inferenceservice.Name,
-			},
+			Labels: managedLabels(inferenceservice.Name, map[string]string{
+				"inferenceservice-name": inferenceServiceLabelValue(inferenceservice),
+			}),
 		},
 		Spec: routev1.RouteSpec{
 			To: routev1.RouteTargetReference{
@@ -58,7 +112,7 @@ func NewInferenceServiceRoute(inferenceservice *inferenceservicev1.InferenceServ
 				TargetPort: intstr.FromInt(modelmeshServicePort),
 			},
 			WildcardPolicy: routev1.WildcardPolicyNone,
-			Path:           "/v2/models/" + inferenceservice.Name,
+			Path:           inferenceServiceModelPath(inferenceservice),
 		},
 		Status: routev1.RouteStatus{
 			Ingress: []routev1.RouteIngress{},
@@ -83,12 +137,31 @@ func NewInferenceServiceRoute(inferenceservice *inferenceservicev1.InferenceServ
 	return finalRoute
 }
 
+// enableRouteRewrite exposes the model endpoints at the root of the route host, the
+// requests being rewritten to the path of the model by the Openshift router
+func enableRouteRewrite(route *routev1.Route, inferenceservice *inferenceservicev1.InferenceService) {
+	if route.Annotations == nil {
+		route.Annotations = map[string]string{}
+	}
+	route.Annotations[routeRewriteTargetAnnotation] = inferenceServiceModelPath(inferenceservice) + "/"
+	route.Spec.Path = "/"
+}
+
 // CompareInferenceServiceRoutes checks if two routes are equal, if not return false
 func CompareInferenceServiceRoutes(r1 routev1.Route, r2 routev1.Route) bool {
-	// Omit the host field since it is reconciled by the ingress controller
-	r1.Spec.Host, r2.Spec.Host = "", ""
+	// Omit the host field since it is reconciled by the ingress controller, unless it is a
+	// custom host
+	if r1.Annotations[customHostnameAnnotation] == "" && r2.Annotations[customHostnameAnnotation] == "" {
+		r1.Spec.Host, r2.Spec.Host = "", ""
+	}
 
-	// Two routes will be equal if the labels and spec are identical
+	// Two routes will be equal if the labels, managed annotations and spec are identical.
+	// The other annotations are omitted since the router adds its own
+	for _, annotation := range routeManagedAnnotations {
+		if r1.Annotations[annotation] != r2.Annotations[annotation] {
+			return false
+		}
+	}
 	return reflect.DeepEqual(r1.ObjectMeta.Labels, r2.ObjectMeta.Labels) &&
 		reflect.DeepEqual(r1.Spec, r2.Spec)
 }
@@ -102,13 +175,19 @@ func (r *OpenshiftInferenceServiceReconciler) reconcileRoute(inferenceservice *i
 
 	enableAuth := true
 	desiredServingRuntime := &predictorv1.ServingRuntime{}
-	err := r.Get(ctx, types.NamespacedName{
-		Name:      *inferenceservice.Spec.Predictor.Model.Runtime,
-		Namespace: inferenceservice.Namespace,
-	}, desiredServingRuntime)
+	runtimeName, err := r.inferenceServiceRuntimeName(ctx, inferenceservice)
 	if err != nil {
+		log.Error(err, "Unable to determine the Serving Runtime")
+		return err
+	}
+	if runtimeName == "" {
+		log.Info("No Serving Runtime can serve the InferenceService " + inferenceservice.Name)
+	} else if err := r.Get(ctx, types.NamespacedName{
+		Name:      runtimeName,
+		Namespace: inferenceservice.Namespace,
+	}, desiredServingRuntime); err != nil {
 		if apierrs.IsNotFound(err) {
-			log.Info("Serving Runtime ", *inferenceservice.Spec.Predictor.Model.Runtime, " desired by ", inferenceservice.Name, "was not found in namespace")
+			log.Info("Serving Runtime " + runtimeName + " desired by " + inferenceservice.Name + " was not found in namespace")
 		}
 	}
 
@@ -120,8 +199,40 @@ func (r *OpenshiftInferenceServiceReconciler) reconcileRoute(inferenceservice *i
 		createRoute = false
 	}
 
+	// The plain HTTP maintenance responder is reached without the auth proxy
+	inMaintenance := inferenceServiceInMaintenance(inferenceservice)
+	if inMaintenance {
+		enableAuth = false
+	}
+
 	// Generate the desired route
 	desiredRoute := newRoute(inferenceservice, enableAuth)
+	if inMaintenance {
+		desiredRoute.Spec.To.Name = inferenceServiceMaintenanceServiceName(inferenceservice)
+	}
+	if desiredServingRuntime.Annotations["enable-route-rewrite"] == "true" {
+		enableRouteRewrite(desiredRoute, inferenceservice)
+	}
+	if host, err := inferenceServiceCustomHost(inferenceservice); err != nil {
+		// Retrying will not fix the annotation, the route keeps its generated host
+		log.Error(err, "Ignoring the custom host name")
+	} else if host != "" {
+		setRouteCustomHost(desiredRoute, host)
+	} else if createRoute && !generatedHostFits(inferenceservice.Namespace) {
+		err := fmt.Errorf("the namespace name is too long for the host name generated by the router, "+
+			"set the %s annotation", customHostnameAnnotation)
+		log.Error(err, "Unable to expose the InferenceService")
+		return err
+	}
+	if err := r.applyRouteTLSPolicy(ctx, desiredRoute, enableAuth); err != nil {
+		log.Error(err, "Unable to apply the TLS policy to the route")
+		return err
+	}
+	if err := r.addPropagatedLabels(ctx, desiredRoute, inferenceservice); err != nil {
+		log.Error(err, "Unable to get the labels to propagate to the route")
+		return err
+	}
+	excludeFromBackup(desiredRoute, r.ExcludeFromBackup)
 
 	// Create the route if it does not already exist
 	foundRoute := &routev1.Route{}
@@ -136,6 +247,15 @@ func (r *OpenshiftInferenceServiceReconciler) reconcileRoute(inferenceservice *i
 			return nil
 		}
 		if apierrs.IsNotFound(err) {
+			allowed, err := r.exposureAllowed(ctx, inferenceservice)
+			if err != nil {
+				log.Error(err, "Unable to check the exposed models quota")
+				return err
+			}
+			if !allowed {
+				log.Info("Exposed models quota of the namespace reached. Skipping route creation")
+				return nil
+			}
 			log.Info("Creating Route")
 			// Add .metatada.ownerReferences to the route to be deleted by the
 			// Kubernetes garbage collector if the predictor is deleted
@@ -150,6 +270,7 @@ func (r *OpenshiftInferenceServiceReconciler) reconcileRoute(inferenceservice *i
 				log.Error(err, "Unable to create the Route")
 				return err
 			}
+			auditLog(AuditActionCreate, "Route", desiredRoute, "InferenceService "+inferenceservice.Name+" exposed")
 			justCreated = true
 		} else {
 			log.Error(err, "Unable to fetch the Route")
@@ -157,9 +278,37 @@ func (r *OpenshiftInferenceServiceReconciler) reconcileRoute(inferenceservice *i
 		}
 	}
 
+	if err := checkNameCollision(foundRoute, inferenceservice); err != nil {
+		log.Error(err, "Unable to reconcile the Route")
+		return err
+	}
+	// Remove the route created before the name was shortened
+	if err := r.deleteLegacyResource(ctx, desiredRoute, inferenceservice.Name, inferenceservice); err != nil {
+		log.Error(err, "Unable to delete the Route with the legacy name")
+		return err
+	}
+
+	if !justCreated && r.pausedByUsers(foundRoute, inferenceservice, log) {
+		return nil
+	}
 	if !createRoute {
 		log.Info("Serving Runtime does not have 'enable-route' annotation set to 'True'. Deleting existing route")
-		return r.Delete(ctx, foundRoute)
+		if err := r.Delete(ctx, foundRoute); err != nil {
+			return err
+		}
+		auditLog(AuditActionDelete, "Route", foundRoute, "Serving Runtime route disabled")
+		return nil
+	}
+	// The router only generates the host of new routes, the route is recreated when its
+	// custom host is removed
+	if !justCreated && desiredRoute.Spec.Host == "" && foundRoute.Annotations[customHostnameAnnotation] != "" {
+		log.Info("Custom host name removed, recreating the Route")
+		if err := r.Delete(ctx, foundRoute); err != nil && !apierrs.IsNotFound(err) {
+			log.Error(err, "Unable to delete the Route")
+			return err
+		}
+		auditLog(AuditActionDelete, "Route", foundRoute, "Custom host name removed")
+		return nil
 	}
 	// Reconcile the route spec if it has been manually modified
 	if !justCreated && !CompareInferenceServiceRoutes(*desiredRoute, *foundRoute) {
@@ -174,15 +323,26 @@ func (r *OpenshiftInferenceServiceReconciler) reconcileRoute(inferenceservice *i
 			}, foundRoute); err != nil {
 				return err
 			}
-			// Reconcile labels and spec field
+			// Reconcile labels, managed annotations and spec field
 			foundRoute.Spec = desiredRoute.Spec
 			foundRoute.ObjectMeta.Labels = desiredRoute.ObjectMeta.Labels
+			for _, annotation := range routeManagedAnnotations {
+				if value, ok := desiredRoute.Annotations[annotation]; ok {
+					if foundRoute.Annotations == nil {
+						foundRoute.Annotations = map[string]string{}
+					}
+					foundRoute.Annotations[annotation] = value
+				} else {
+					delete(foundRoute.Annotations, annotation)
+				}
+			}
 			return r.Update(ctx, foundRoute)
 		})
 		if err != nil {
 			log.Error(err, "Unable to reconcile the Route")
 			return err
 		}
+		auditLog(AuditActionUpdate, "Route", foundRoute, "Route reverted to the desired state")
 	}
 
 	return nil
@@ -0,0 +1,102 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// routeHSTSAnnotation sets the Strict-Transport-Security header of the responses
+	routeHSTSAnnotation = "haproxy.router.openshift.io/hsts_header"
+)
+
+// RouteTLSPolicy is the cluster level TLS configuration of the routes created by the
+// controller, the zero value keeps the default policy
+type RouteTLSPolicy struct {
+	// Termination of the TLS connections of the routes of the runtimes with auth enabled:
+	// reencrypt or passthrough, reencrypt when empty. The routes of the other runtimes use
+	// edge, the only termination supported by the plain HTTP modelmesh port
+	Termination routev1.TLSTerminationType
+	// CertificateSecretName is the name of a secret of the namespace of the InferenceService
+	// with the tls.crt, tls.key and optional ca.crt keys, served by the router instead of its
+	// default certificate. The certificate of a namespace is only exposed to the users able
+	// to read its routes, the namespaces without the secret use the default certificate
+	CertificateSecretName string
+	// HSTSHeader is the value of the Strict-Transport-Security header, e.g.
+	// "max-age=31536000;includeSubDomains"
+	HSTSHeader string
+}
+
+// ParseRouteTLSTermination validates the TLS termination of the routes of the runtimes with
+// auth enabled, the auth proxy only accepts TLS connections so edge is rejected
+func ParseRouteTLSTermination(termination string) (routev1.TLSTerminationType, error) {
+	switch routev1.TLSTerminationType(termination) {
+	case "", routev1.TLSTerminationReencrypt, routev1.TLSTerminationPassthrough:
+		return routev1.TLSTerminationType(termination), nil
+	case routev1.TLSTerminationEdge:
+		return "", fmt.Errorf("the edge route TLS termination is not supported by the auth proxy, " +
+			"expected reencrypt or passthrough")
+	}
+	return "", fmt.Errorf("unknown route TLS termination %q, expected reencrypt or passthrough", termination)
+}
+
+// applyRouteTLSPolicy configures the TLS of the desired route according to the policy
+func (r *OpenshiftInferenceServiceReconciler) applyRouteTLSPolicy(ctx context.Context, route *routev1.Route,
+	enableAuth bool) error {
+	policy := r.RouteTLSPolicy
+	if policy.Termination != "" && enableAuth {
+		route.Spec.TLS.Termination = policy.Termination
+	}
+
+	if policy.CertificateSecretName != "" && route.Spec.TLS.Termination != routev1.TLSTerminationPassthrough {
+		secret := &corev1.Secret{}
+		err := r.Get(ctx, types.NamespacedName{Name: policy.CertificateSecretName, Namespace: route.Namespace}, secret)
+		if err != nil && !apierrs.IsNotFound(err) {
+			return err
+		}
+		if err == nil {
+			route.Spec.TLS.Certificate = string(secret.Data[corev1.TLSCertKey])
+			route.Spec.TLS.Key = string(secret.Data[corev1.TLSPrivateKeyKey])
+			route.Spec.TLS.CACertificate = string(secret.Data["ca.crt"])
+		}
+	}
+
+	if policy.HSTSHeader != "" {
+		if route.Annotations == nil {
+			route.Annotations = map[string]string{}
+		}
+		route.Annotations[routeHSTSAnnotation] = policy.HSTSHeader
+	}
+	return nil
+}
+
+// requeueRouteCertificateInferenceServices returns the reconcile requests of the
+// InferenceServices of the namespace when its route certificate secret is modified
+func (r *OpenshiftInferenceServiceReconciler) requeueRouteCertificateInferenceServices(o client.Object) []reconcile.Request {
+	if o.GetName() != r.RouteTLSPolicy.CertificateSecretName {
+		return []reconcile.Request{}
+	}
+	return r.requeueNamespaceInferenceServices(o.GetNamespace())
+}
@@ -0,0 +1,120 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+
+	predictorv1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// autoSelectedRuntimeIndexValue indexes the InferenceServices without an explicit
+	// runtime, which ModelMesh deploys on a runtime selected by their model format. It
+	// cannot collide with a runtime name
+	autoSelectedRuntimeIndexValue = "+auto"
+)
+
+// inferenceServiceRuntimeIndexValue returns the value of the runtime index of the
+// InferenceService, see inferenceServiceRuntimeField
+func inferenceServiceRuntimeIndexValue(inferenceservice *inferenceservicev1.InferenceService) string {
+	if model := inferenceservice.Spec.Predictor.Model; model != nil && model.Runtime != nil {
+		return *model.Runtime
+	}
+	return autoSelectedRuntimeIndexValue
+}
+
+// inferenceServiceModelFormat returns the model format of the InferenceService, either
+// set in the model spec or given by the deprecated predictor fields
+func inferenceServiceModelFormat(inferenceservice *inferenceservicev1.InferenceService) string {
+	predictor := inferenceservice.Spec.Predictor
+	if predictor.Model != nil {
+		return predictor.Model.ModelFormat.Name
+	}
+	deprecatedFormats := []struct {
+		name string
+		spec *inferenceservicev1.PredictorExtensionSpec
+	}{
+		{"sklearn", predictor.SKLearn},
+		{"xgboost", predictor.XGBoost},
+		{"tensorflow", predictor.Tensorflow},
+		{"pytorch", predictor.PyTorch},
+		{"triton", predictor.Triton},
+		{"onnx", predictor.ONNX},
+		{"pmml", predictor.PMML},
+		{"lightgbm", predictor.LightGBM},
+		{"paddle", predictor.Paddle},
+	}
+	for _, format := range deprecatedFormats {
+		if format.spec != nil {
+			return format.name
+		}
+	}
+	return ""
+}
+
+// selectServingRuntime returns the name of the first enabled runtime, by name, that
+// auto-selects the model format, or an empty string if there is none
+func selectServingRuntime(servingRuntimes []predictorv1.ServingRuntime, format string) string {
+	sort.Slice(servingRuntimes, func(i, j int) bool {
+		return servingRuntimes[i].Name < servingRuntimes[j].Name
+	})
+	for _, servingRuntime := range servingRuntimes {
+		if servingRuntime.Disabled() {
+			continue
+		}
+		for _, supported := range servingRuntime.Spec.SupportedModelFormats {
+			if supported.Name == format && supported.AutoSelect != nil && *supported.AutoSelect {
+				return servingRuntime.Name
+			}
+		}
+	}
+	return ""
+}
+
+// inferenceServiceRuntimeName returns the name of the serving runtime of the
+// InferenceService: the one set in its model spec, otherwise the one ModelMesh selects for
+// its model format. An empty name is returned when the runtime cannot be determined
+func (r *OpenshiftInferenceServiceReconciler) inferenceServiceRuntimeName(ctx context.Context,
+	inferenceservice *inferenceservicev1.InferenceService) (string, error) {
+	if runtimeName := explicitRuntimeName(inferenceservice); runtimeName != "" {
+		return runtimeName, nil
+	}
+	format := inferenceServiceModelFormat(inferenceservice)
+	if format == "" {
+		return "", nil
+	}
+	servingRuntimes := &predictorv1.ServingRuntimeList{}
+	if err := r.List(ctx, servingRuntimes, client.InNamespace(inferenceservice.Namespace)); err != nil {
+		return "", err
+	}
+	return selectServingRuntime(servingRuntimes.Items, format), nil
+}
+
+// explicitRuntimeName returns the name of the serving runtime set in the model spec of the
+// InferenceService, or an empty string when ModelMesh selects it. The runtime selected by
+// ModelMesh also depends on the placement of the models, it may differ from the one found
+// by selectServingRuntime, so the sub-reconcilers modifying the runtime only handle the
+// InferenceServices with an explicit one
+func explicitRuntimeName(inferenceservice *inferenceservicev1.InferenceService) string {
+	if model := inferenceservice.Spec.Predictor.Model; model != nil && model.Runtime != nil {
+		return *model.Runtime
+	}
+	return ""
+}
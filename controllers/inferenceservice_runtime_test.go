@@ -0,0 +1,77 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	predictorv1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newAutoSelectRuntime returns a runtime supporting the model format, auto-selected for it
+// when autoSelect is true
+func newAutoSelectRuntime(name string, format string, autoSelect bool, disabled bool) predictorv1.ServingRuntime {
+	return predictorv1.ServingRuntime{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: predictorv1.ServingRuntimeSpec{
+			SupportedModelFormats: []predictorv1.SupportedModelFormat{{Name: format, AutoSelect: &autoSelect}},
+			Disabled:              &disabled,
+		},
+	}
+}
+
+var _ = Describe("The serving runtime of an InferenceService", func() {
+
+	DescribeTable("Should be selected like ModelMesh does",
+		func(servingRuntimes []predictorv1.ServingRuntime, expected string) {
+			Expect(selectServingRuntime(servingRuntimes, "onnx")).To(Equal(expected))
+		},
+		Entry("when no runtime auto-selects the format", []predictorv1.ServingRuntime{
+			newAutoSelectRuntime("ovms", "onnx", false, false),
+			newAutoSelectRuntime("triton", "tensorflow", true, false),
+		}, ""),
+		Entry("when several runtimes auto-select the format", []predictorv1.ServingRuntime{
+			newAutoSelectRuntime("triton", "onnx", true, false),
+			newAutoSelectRuntime("ovms", "onnx", true, false),
+		}, "ovms"),
+		Entry("when the first runtime is disabled", []predictorv1.ServingRuntime{
+			newAutoSelectRuntime("ovms", "onnx", true, true),
+			newAutoSelectRuntime("triton", "onnx", true, false),
+		}, "triton"),
+	)
+
+	It("Should only be modified when it is set explicitly", func() {
+		runtimeName := "ovms"
+		inferenceService := &inferenceservicev1.InferenceService{}
+		inferenceService.Spec.Predictor.Model = &inferenceservicev1.ModelSpec{Runtime: &runtimeName}
+		Expect(explicitRuntimeName(inferenceService)).To(Equal(runtimeName))
+		Expect(inferenceServiceRuntimeIndexValue(inferenceService)).To(Equal(runtimeName))
+
+		inferenceService.Spec.Predictor.Model.Runtime = nil
+		Expect(explicitRuntimeName(inferenceService)).To(BeEmpty())
+		Expect(inferenceServiceRuntimeIndexValue(inferenceService)).To(Equal(autoSelectedRuntimeIndexValue))
+	})
+
+	It("Should be selected from the model format of the deprecated predictor fields", func() {
+		inferenceService := &inferenceservicev1.InferenceService{}
+		inferenceService.Spec.Predictor.ONNX = &inferenceservicev1.PredictorExtensionSpec{}
+		Expect(inferenceServiceModelFormat(inferenceService)).To(Equal("onnx"))
+		Expect(explicitRuntimeName(inferenceService)).To(BeEmpty())
+	})
+})
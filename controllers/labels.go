@@ -0,0 +1,169 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// managedByLabel, partOfLabel and instanceLabel are the recommended labels set on the
+	// resources created by the controller, so that backup and disaster recovery tooling can
+	// select them
+	managedByLabel = "app.kubernetes.io/managed-by"
+	partOfLabel    = "app.kubernetes.io/part-of"
+	instanceLabel  = "app.kubernetes.io/instance"
+	controllerName = "odh-model-controller"
+	partOfValue    = "model-serving"
+	// backupExcludeLabel excludes a resource from the Velero backups
+	backupExcludeLabel = "velero.io/exclude-from-backup"
+	// controllerVersionLabel stamps the resources with the version of the controller that
+	// last reconciled them, so that the Pruner can find the stale ones
+	controllerVersionLabel = "opendatahub.io/controller-version"
+)
+
+// ControllerVersion is the version of the controller, set at build time with
+// -ldflags "-X github.com/opendatahub-io/odh-model-controller/controllers.ControllerVersion=<version>"
+var ControllerVersion = "dev"
+
+// managedLabels returns the labels of a resource created by the controller for the instance,
+// the InferenceService or the namespace it belongs to, merged with the given labels
+func managedLabels(instance string, labels map[string]string) map[string]string {
+	managed := map[string]string{
+		managedByLabel: controllerName,
+		partOfLabel:    partOfValue,
+		instanceLabel:  truncateName(instance, maxNameLength),
+		// The stale resources of the managed kinds are stamped with the running version
+		// when they are reconciled
+		controllerVersionLabel: ControllerVersion,
+	}
+	for key, value := range labels {
+		managed[key] = value
+	}
+	return managed
+}
+
+// excludeFromBackup labels the desired object to be excluded from the Velero backups when
+// enabled, the resources created by the controller being regenerated after a restore
+func excludeFromBackup(obj client.Object, enabled bool) {
+	if !enabled {
+		return
+	}
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[backupExcludeLabel] = "true"
+	obj.SetLabels(labels)
+}
+
+// managementPaused returns true if the users took over a resource created by the
+// controller by setting the opendatahub.io/managed annotation to false, the controller
+// then stops updating and deleting it
+func managementPaused(obj client.Object, log logr.Logger) bool {
+	if obj.GetAnnotations()["opendatahub.io/managed"] != "false" {
+		return false
+	}
+	log.Info("Reconciliation of " + obj.GetName() + " paused by the opendatahub.io/managed annotation")
+	return true
+}
+
+// managementPausedReason is the reason of the events recorded when the reconciliation of a
+// resource taken over by the users is paused
+const managementPausedReason = "ManagementPaused"
+
+// pausedByUsers returns true if the users took over the resource, as managementPaused, and
+// then records a ManagementPaused event on its owner, e.g. the InferenceService, so that the
+// users see why it is no longer reconciled. The event is recorded on the resource itself
+// when it has no owner
+func (r *OpenshiftInferenceServiceReconciler) pausedByUsers(obj client.Object, owner client.Object,
+	log logr.Logger) bool {
+	if !managementPaused(obj, log) {
+		return false
+	}
+	if owner == nil {
+		owner = obj
+	}
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	if kind == "" {
+		kind = reflect.Indirect(reflect.ValueOf(obj)).Type().Name()
+	}
+	if r.Recorder != nil {
+		r.Recorder.Eventf(owner, corev1.EventTypeNormal, managementPausedReason,
+			"Reconciliation of the %s %s paused by the opendatahub.io/managed annotation", kind, obj.GetName())
+	}
+	return true
+}
+
+// propagatedLabels returns the labels of the PropagatedLabels keys to set on the resources
+// created for the InferenceService. The values are taken from the InferenceService, falling
+// back to its namespace. A nil InferenceService is used for the resources shared by the
+// namespace, which only get the labels of the namespace
+func (r *OpenshiftInferenceServiceReconciler) propagatedLabels(ctx context.Context, namespace string,
+	inferenceservice *inferenceservicev1.InferenceService) (map[string]string, error) {
+	labels := map[string]string{}
+	if len(r.PropagatedLabels) == 0 {
+		return labels, nil
+	}
+
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		return nil, err
+	}
+	for _, key := range r.PropagatedLabels {
+		if inferenceservice != nil {
+			if value, ok := inferenceservice.Labels[key]; ok {
+				labels[key] = value
+				continue
+			}
+		}
+		if value, ok := ns.Labels[key]; ok {
+			labels[key] = value
+		}
+	}
+	return labels, nil
+}
+
+// addPropagatedLabels sets the propagated labels on the desired object, keeping the labels
+// the controller relies on
+func (r *OpenshiftInferenceServiceReconciler) addPropagatedLabels(ctx context.Context, obj client.Object,
+	inferenceservice *inferenceservicev1.InferenceService) error {
+	labels, err := r.propagatedLabels(ctx, obj.GetNamespace(), inferenceservice)
+	if err != nil {
+		return err
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	objLabels := obj.GetLabels()
+	if objLabels == nil {
+		objLabels = map[string]string{}
+	}
+	for key, value := range labels {
+		if _, ok := objLabels[key]; !ok {
+			objLabels[key] = value
+		}
+	}
+	obj.SetLabels(objLabels)
+	return nil
+}
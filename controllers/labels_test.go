@@ -0,0 +1,47 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	routev1 "github.com/openshift/api/route/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("The resources taken over by the users", func() {
+	inferenceService := &inferenceservicev1.InferenceService{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-onnx-mnist", Namespace: WorkingNamespace},
+	}
+
+	It("Should record a ManagementPaused event on the InferenceService", func() {
+		recorder := record.NewFakeRecorder(1)
+		reconciler := &OpenshiftInferenceServiceReconciler{Recorder: recorder}
+		log := ctrl.Log.WithName("controllers")
+		route := &routev1.Route{ObjectMeta: metav1.ObjectMeta{Name: inferenceService.Name}}
+
+		Expect(reconciler.pausedByUsers(route, inferenceService, log)).To(BeFalse())
+		Expect(recorder.Events).To(BeEmpty())
+
+		route.Annotations = map[string]string{"opendatahub.io/managed": "false"}
+		Expect(reconciler.pausedByUsers(route, inferenceService, log)).To(BeTrue())
+		Expect(<-recorder.Events).To(Equal("Normal " + managementPausedReason +
+			" Reconciliation of the Route example-onnx-mnist paused by the opendatahub.io/managed annotation"))
+	})
+})
@@ -0,0 +1,140 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crzap "sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// LogLevels creates the loggers of the reconcilers, each with its own level that can be
+// tuned at runtime, e.g. to debug a single reconciler in production
+type LogLevels struct {
+	mu           sync.Mutex
+	levels       map[string]zap.AtomicLevel
+	defaultLevel zapcore.Level
+	opts         []crzap.Opts
+}
+
+// NewLogLevels returns the LogLevels creating the loggers with the options, the level of
+// the loggers not configured at runtime being the default one
+func NewLogLevels(defaultLevel zapcore.Level, opts ...crzap.Opts) *LogLevels {
+	return &LogLevels{
+		levels:       map[string]zap.AtomicLevel{},
+		defaultLevel: defaultLevel,
+		opts:         opts,
+	}
+}
+
+// Logger returns the logger with the name, whose level is configured by the name. The name
+// is the one printed in the logs, e.g. "controllers.InferenceService"
+func (l *LogLevels) Logger(name string) logr.Logger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	level, ok := l.levels[name]
+	if !ok {
+		level = zap.NewAtomicLevelAt(l.defaultLevel)
+		l.levels[name] = level
+	}
+	opts := append(append([]crzap.Opts{}, l.opts...), crzap.Level(level))
+	logger := crzap.New(opts...)
+	for _, element := range strings.Split(name, ".") {
+		logger = logger.WithName(element)
+	}
+	return logger
+}
+
+// ParseLogLevel parses a zap level name (debug, info, error) or a logr verbosity
+func ParseLogLevel(value string) (zapcore.Level, error) {
+	value = strings.TrimSpace(value)
+	if verbosity, err := strconv.Atoi(value); err == nil {
+		return zapcore.Level(-verbosity), nil
+	}
+	var level zapcore.Level
+	err := level.UnmarshalText([]byte(value))
+	return level, err
+}
+
+// Set updates the levels of the loggers, the loggers missing from the map are reset to
+// the default level
+func (l *LogLevels) Set(levels map[string]zapcore.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for name, atomicLevel := range l.levels {
+		if level, ok := levels[name]; ok {
+			atomicLevel.SetLevel(level)
+		} else {
+			atomicLevel.SetLevel(l.defaultLevel)
+		}
+	}
+}
+
+// LogLevelReconciler applies the levels of the loggers set in a ConfigMap, whose keys are
+// the names of the loggers and values their levels
+type LogLevelReconciler struct {
+	client.Client
+	Log       logr.Logger
+	LogLevels *LogLevels
+	ConfigMap types.NamespacedName
+}
+
+// Reconcile applies the levels of the ConfigMap, or the default levels when it is removed
+func (r *LogLevelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	configMap := &corev1.ConfigMap{}
+	err := r.Get(ctx, r.ConfigMap, configMap)
+	if err != nil && !apierrs.IsNotFound(err) {
+		r.Log.Error(err, "Unable to fetch the log levels ConfigMap")
+		return ctrl.Result{}, err
+	}
+
+	levels := map[string]zapcore.Level{}
+	for name, value := range configMap.Data {
+		level, err := ParseLogLevel(value)
+		if err != nil {
+			// Retrying will not fix the value, wait for the ConfigMap to be updated
+			r.Log.Error(err, "Invalid log level", "logger", name, "level", value)
+			continue
+		}
+		levels[name] = level
+	}
+	r.LogLevels.Set(levels)
+	r.Log.Info("Log levels updated", "levels", configMap.Data)
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager
+func (r *LogLevelReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("loglevels").
+		For(&corev1.ConfigMap{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(o client.Object) bool {
+			return o.GetNamespace() == r.ConfigMap.Namespace && o.GetName() == r.ConfigMap.Name
+		}))).
+		Complete(r)
+}
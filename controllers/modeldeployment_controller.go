@@ -0,0 +1,307 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"github.com/kserve/modelmesh-serving/apis/serving/common"
+	predictorv1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	templatev1 "github.com/openshift/api/template/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlbuilder "sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// deployIntentLabel marks the ConfigMaps, written by the model registry, requesting the
+	// deployment of a registered model
+	deployIntentLabel = "modelregistry.opendatahub.io/deploy-intent"
+	// deployIntentOwnerLabel references the deploy intent of the InferenceService
+	deployIntentOwnerLabel = "modelregistry.opendatahub.io/deploy-intent-name"
+	// The keys of the deploy intent ConfigMaps
+	deployIntentName            = "name"
+	deployIntentModelFormat     = "modelFormat"
+	deployIntentModelVersion    = "modelFormatVersion"
+	deployIntentStorageURI      = "storageUri"
+	deployIntentStorageKey      = "storageKey"
+	deployIntentStoragePath     = "storagePath"
+	deployIntentRuntime         = "runtime"
+	deployIntentRuntimeTemplate = "runtimeTemplate"
+	// deploymentModeAnnotation selects the ModelMesh deployment of the InferenceService
+	deploymentModeAnnotation = "serving.kserve.io/deploymentMode"
+	modelMeshDeploymentMode  = "ModelMesh"
+)
+
+// ModelDeploymentReconciler materializes the deploy intents of the model registry, ConfigMaps
+// labeled modelregistry.opendatahub.io/deploy-intent, into an InferenceService and, when
+// missing, its ServingRuntime rendered from a ServingRuntime Template
+type ModelDeploymentReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Log    logr.Logger
+	// TemplatesNS is the namespace of the ServingRuntime Templates
+	TemplatesNS string
+}
+
+// +kubebuilder:rbac:groups=serving.kserve.io,resources=inferenceservices,verbs=get;list;watch;create;update
+
+// NewDeployIntentInferenceService defines the InferenceService requested by the deploy intent
+func NewDeployIntentInferenceService(intent *corev1.ConfigMap) (*inferenceservicev1.InferenceService, error) {
+	data := intent.Data
+	if data[deployIntentModelFormat] == "" {
+		return nil, fmt.Errorf("the %s key of the deploy intent is required", deployIntentModelFormat)
+	}
+	if data[deployIntentStorageURI] == "" && data[deployIntentStoragePath] == "" {
+		return nil, fmt.Errorf("the %s or %s key of the deploy intent is required", deployIntentStorageURI,
+			deployIntentStoragePath)
+	}
+	name := data[deployIntentName]
+	if name == "" {
+		name = intent.Name
+	}
+
+	model := &inferenceservicev1.ModelSpec{
+		ModelFormat: inferenceservicev1.ModelFormat{Name: data[deployIntentModelFormat]},
+	}
+	if version := data[deployIntentModelVersion]; version != "" {
+		model.ModelFormat.Version = &version
+	}
+	if runtimeName := deployIntentRuntimeName(intent); runtimeName != "" {
+		model.Runtime = &runtimeName
+	}
+	if uri := data[deployIntentStorageURI]; uri != "" {
+		model.StorageURI = &uri
+	} else {
+		path := data[deployIntentStoragePath]
+		model.Storage = &common.StorageSpec{Path: &path}
+		if key := data[deployIntentStorageKey]; key != "" {
+			model.Storage.StorageKey = &key
+		}
+	}
+
+	return &inferenceservicev1.InferenceService{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: intent.Namespace,
+			Labels: managedLabels(name, map[string]string{
+				deployIntentOwnerLabel: intent.Name,
+			}),
+			Annotations: map[string]string{deploymentModeAnnotation: modelMeshDeploymentMode},
+		},
+		Spec: inferenceservicev1.InferenceServiceSpec{
+			Predictor: inferenceservicev1.InferenceServicePredictorSpec{Model: model},
+		},
+	}, nil
+}
+
+// deployIntentRuntimeName returns the ServingRuntime of the deploy intent, the name of its
+// Template when it is not set. It is empty when the runtime is auto-selected
+func deployIntentRuntimeName(intent *corev1.ConfigMap) string {
+	if name := intent.Data[deployIntentRuntime]; name != "" {
+		return name
+	}
+	return intent.Data[deployIntentRuntimeTemplate]
+}
+
+// CompareDeployIntentInferenceServices checks if the found InferenceService has the labels,
+// annotation and predictor of the desired one, if not return false. The labels added by the
+// users or the other controllers are not compared
+func CompareDeployIntentInferenceServices(desired inferenceservicev1.InferenceService,
+	found inferenceservicev1.InferenceService) bool {
+	for key, value := range desired.ObjectMeta.Labels {
+		if foundValue, ok := found.ObjectMeta.Labels[key]; !ok || foundValue != value {
+			return false
+		}
+	}
+	return desired.Annotations[deploymentModeAnnotation] == found.Annotations[deploymentModeAnnotation] &&
+		reflect.DeepEqual(desired.Spec.Predictor, found.Spec.Predictor)
+}
+
+// reconcileRuntime creates the ServingRuntime of the deploy intent from its Template when it
+// does not exist. The ServingRuntime is shared by the models deployed on it, so it is neither
+// updated nor deleted with the deploy intent
+func (r *ModelDeploymentReconciler) reconcileRuntime(ctx context.Context, intent *corev1.ConfigMap,
+	log logr.Logger) error {
+	templateName := intent.Data[deployIntentRuntimeTemplate]
+	if templateName == "" {
+		return nil
+	}
+	runtimeKey := types.NamespacedName{Name: deployIntentRuntimeName(intent), Namespace: intent.Namespace}
+	err := r.Get(ctx, runtimeKey, &predictorv1.ServingRuntime{})
+	if err == nil {
+		return nil
+	} else if !apierrs.IsNotFound(err) {
+		log.Error(err, "Unable to fetch the Serving Runtime")
+		return err
+	}
+
+	// The TemplatesNS is empty when the cluster does not serve the Template API
+	if r.TemplatesNS == "" {
+		err := fmt.Errorf("the ServingRuntime Templates are not available")
+		log.Error(err, "Unable to create the Serving Runtime from the Template "+templateName)
+		return err
+	}
+	template := &templatev1.Template{}
+	err = r.Get(ctx, types.NamespacedName{Name: templateName, Namespace: r.TemplatesNS}, template)
+	if err != nil {
+		log.Error(err, "Unable to fetch the Template "+templateName)
+		return err
+	}
+	servingRuntime := &predictorv1.ServingRuntime{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      runtimeKey.Name,
+			Namespace: runtimeKey.Namespace,
+			Labels:    managedLabels(runtimeKey.Namespace, map[string]string{}),
+			// The ServingRuntime can then be kept in sync with its Template
+			Annotations: map[string]string{templateNameAnnotation: templateName},
+		},
+	}
+	values, err := templateParameters(template, servingRuntime)
+	if err != nil {
+		log.Error(err, "Unable to get the parameters of the Template "+templateName)
+		return err
+	}
+	spec, err := renderServingRuntimeTemplate(template, values)
+	if err != nil {
+		log.Error(err, "Unable to render the Template "+templateName)
+		return err
+	}
+	servingRuntime.Spec = *spec
+
+	log.Info("Creating Serving Runtime from the Template " + templateName)
+	if err := r.Create(ctx, servingRuntime); err != nil && !apierrs.IsAlreadyExists(err) {
+		log.Error(err, "Unable to create the Serving Runtime")
+		return err
+	}
+	auditLog(AuditActionCreate, "ServingRuntime", servingRuntime, "Deploy intent "+intent.Name)
+	return nil
+}
+
+// Reconcile creates, or updates, the InferenceService of the deploy intent. The
+// InferenceService is owned by the deploy intent, and deleted with it
+func (r *ModelDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// Initialize logger format
+	log := r.Log.WithValues("ConfigMap", req.Name, "namespace", req.Namespace)
+
+	intent := &corev1.ConfigMap{}
+	err := r.Get(ctx, req.NamespacedName, intent)
+	if apierrs.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the deploy intent")
+		return ctrl.Result{}, err
+	}
+	if intent.Labels[deployIntentLabel] != "true" {
+		return ctrl.Result{}, nil
+	}
+
+	// Retrying will not fix the deploy intent, it is reported in the logs
+	desiredInferenceService, err := NewDeployIntentInferenceService(intent)
+	if err != nil {
+		log.Error(err, "Invalid deploy intent")
+		return ctrl.Result{}, nil
+	}
+	if err := r.reconcileRuntime(ctx, intent, log); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	foundInferenceService := &inferenceservicev1.InferenceService{}
+	key := types.NamespacedName{Name: desiredInferenceService.Name, Namespace: desiredInferenceService.Namespace}
+	err = r.Get(ctx, key, foundInferenceService)
+	if apierrs.IsNotFound(err) {
+		log.Info("Creating InferenceService " + desiredInferenceService.Name)
+		// Add .metatada.ownerReferences to the InferenceService to be deleted by the
+		// Kubernetes garbage collector if the deploy intent is deleted
+		if err := ctrl.SetControllerReference(intent, desiredInferenceService, r.Scheme); err != nil {
+			log.Error(err, "Unable to add OwnerReference to the InferenceService")
+			return ctrl.Result{}, err
+		}
+		if err := r.Create(ctx, desiredInferenceService); err != nil && !apierrs.IsAlreadyExists(err) {
+			log.Error(err, "Unable to create the InferenceService")
+			return ctrl.Result{}, err
+		}
+		auditLog(AuditActionCreate, "InferenceService", desiredInferenceService, "Deploy intent "+intent.Name)
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the InferenceService")
+		return ctrl.Result{}, err
+	}
+
+	// An InferenceService deployed by other means is not taken over
+	if !metav1.IsControlledBy(foundInferenceService, intent) {
+		log.Error(fmt.Errorf("the InferenceService %s is not owned by the deploy intent", key.Name),
+			"Unable to reconcile the InferenceService")
+		return ctrl.Result{}, nil
+	}
+	if managementPaused(foundInferenceService, log) {
+		return ctrl.Result{}, nil
+	}
+
+	// Reconcile the InferenceService if the deploy intent has been updated, e.g. with a new
+	// version of the model, or the InferenceService manually modified
+	if !CompareDeployIntentInferenceServices(*desiredInferenceService, *foundInferenceService) {
+		log.Info("Reconciling InferenceService " + key.Name)
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			// Get the last InferenceService revision
+			if err := r.Get(ctx, key, foundInferenceService); err != nil {
+				return err
+			}
+			if foundInferenceService.Annotations == nil {
+				foundInferenceService.Annotations = map[string]string{}
+			}
+			foundInferenceService.Annotations[deploymentModeAnnotation] = modelMeshDeploymentMode
+			// Only the labels of the deploy intent are owned, the other ones are kept
+			if foundInferenceService.Labels == nil {
+				foundInferenceService.Labels = map[string]string{}
+			}
+			for key, value := range desiredInferenceService.Labels {
+				foundInferenceService.Labels[key] = value
+			}
+			foundInferenceService.Spec.Predictor = desiredInferenceService.Spec.Predictor
+			return r.Update(ctx, foundInferenceService)
+		})
+		if err != nil {
+			log.Error(err, "Unable to reconcile the InferenceService")
+			return ctrl.Result{}, err
+		}
+		auditLog(AuditActionUpdate, "InferenceService", foundInferenceService, "Deploy intent "+intent.Name+" updated")
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ModelDeploymentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	deployIntents := predicate.NewPredicateFuncs(func(o client.Object) bool {
+		return o.GetLabels()[deployIntentLabel] == "true"
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("modeldeployment").
+		For(&corev1.ConfigMap{}, ctrlbuilder.WithPredicates(deployIntents)).
+		Owns(&inferenceservicev1.InferenceService{}).
+		Complete(r)
+}
@@ -0,0 +1,167 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// newDeployIntent returns a deploy intent ConfigMap with the data
+func newDeployIntent(name string, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: WorkingNamespace,
+			Labels:    map[string]string{deployIntentLabel: "true"},
+		},
+		Data: data,
+	}
+}
+
+var _ = Describe("The ModelDeployment controller", func() {
+
+	DescribeTable("Should reject the incomplete deploy intents",
+		func(data map[string]string) {
+			_, err := NewDeployIntentInferenceService(newDeployIntent("intent", data))
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("when the model format is missing", map[string]string{deployIntentStorageURI: "s3://models/mnist"}),
+		Entry("when the storage is missing", map[string]string{deployIntentModelFormat: "onnx"}),
+	)
+
+	It("Should define the InferenceService of the deploy intent", func() {
+		inferenceService, err := NewDeployIntentInferenceService(newDeployIntent("intent", map[string]string{
+			deployIntentName:            "mnist",
+			deployIntentModelFormat:     "onnx",
+			deployIntentModelVersion:    "1",
+			deployIntentStoragePath:     "models/mnist",
+			deployIntentStorageKey:      "my-storage",
+			deployIntentRuntimeTemplate: "ovms",
+		}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inferenceService.Name).To(Equal("mnist"))
+		Expect(inferenceService.Labels[deployIntentOwnerLabel]).To(Equal("intent"))
+		Expect(inferenceService.Annotations[deploymentModeAnnotation]).To(Equal(modelMeshDeploymentMode))
+		model := inferenceService.Spec.Predictor.Model
+		Expect(model.ModelFormat.Name).To(Equal("onnx"))
+		Expect(*model.ModelFormat.Version).To(Equal("1"))
+		Expect(*model.Runtime).To(Equal("ovms"))
+		Expect(model.StorageURI).To(BeNil())
+		Expect(*model.Storage.Path).To(Equal("models/mnist"))
+		Expect(*model.Storage.StorageKey).To(Equal("my-storage"))
+	})
+
+	Context("When a deploy intent is created", func() {
+		var reconciler *ModelDeploymentReconciler
+
+		BeforeEach(func() {
+			// The reconciler is called directly, no Template is needed without runtimeTemplate
+			reconciler = &ModelDeploymentReconciler{
+				Client: cli,
+				Log:    ctrl.Log.WithName("controllers").WithName("modeldeployment-controller"),
+				Scheme: scheme.Scheme,
+			}
+		})
+
+		reconcileIntent := func(ctx context.Context, intent *corev1.ConfigMap) {
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{
+				Name: intent.Name, Namespace: intent.Namespace,
+			}})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		It("Should create and update its InferenceService, keeping the labels it does not own", func() {
+			ctx := context.Background()
+			intent := newDeployIntent("mnist-intent", map[string]string{
+				deployIntentName:        "mnist-intent-model",
+				deployIntentModelFormat: "onnx",
+				deployIntentStorageURI:  "s3://models/mnist/1",
+			})
+			Expect(cli.Create(ctx, intent)).Should(Succeed())
+			defer func() {
+				Expect(cli.Delete(ctx, intent)).Should(Succeed())
+			}()
+			reconcileIntent(ctx, intent)
+
+			By("By checking that the InferenceService is created and owned by the deploy intent")
+
+			key := types.NamespacedName{Name: "mnist-intent-model", Namespace: WorkingNamespace}
+			inferenceService := &inferenceservicev1.InferenceService{}
+			Expect(cli.Get(ctx, key, inferenceService)).Should(Succeed())
+			Expect(metav1.IsControlledBy(inferenceService, intent)).To(BeTrue())
+			Expect(*inferenceService.Spec.Predictor.Model.StorageURI).To(Equal("s3://models/mnist/1"))
+
+			By("By checking that the InferenceService is updated with the deploy intent")
+
+			// The InferenceService controller may update it concurrently
+			Expect(retry.RetryOnConflict(retry.DefaultRetry, func() error {
+				if err := cli.Get(ctx, key, inferenceService); err != nil {
+					return err
+				}
+				inferenceService.Labels["team"] = "fraud-detection"
+				return cli.Update(ctx, inferenceService)
+			})).Should(Succeed())
+			intent.Data[deployIntentStorageURI] = "s3://models/mnist/2"
+			Expect(cli.Update(ctx, intent)).Should(Succeed())
+			reconcileIntent(ctx, intent)
+
+			Expect(cli.Get(ctx, key, inferenceService)).Should(Succeed())
+			Expect(*inferenceService.Spec.Predictor.Model.StorageURI).To(Equal("s3://models/mnist/2"))
+			Expect(inferenceService.Labels).To(HaveKeyWithValue("team", "fraud-detection"))
+			Expect(inferenceService.Labels).To(HaveKeyWithValue(deployIntentOwnerLabel, intent.Name))
+		})
+
+		It("Should not take over an InferenceService deployed by other means", func() {
+			ctx := context.Background()
+			storageURI := "s3://models/mnist/1"
+			inferenceService := &inferenceservicev1.InferenceService{
+				ObjectMeta: metav1.ObjectMeta{Name: "user-model", Namespace: WorkingNamespace},
+			}
+			inferenceService.Spec.Predictor.Model = &inferenceservicev1.ModelSpec{
+				ModelFormat: inferenceservicev1.ModelFormat{Name: "onnx"},
+			}
+			inferenceService.Spec.Predictor.Model.StorageURI = &storageURI
+			Expect(cli.Create(ctx, inferenceService)).Should(Succeed())
+
+			intent := newDeployIntent("user-model-intent", map[string]string{
+				deployIntentName:        inferenceService.Name,
+				deployIntentModelFormat: "onnx",
+				deployIntentStorageURI:  "s3://models/mnist/2",
+			})
+			Expect(cli.Create(ctx, intent)).Should(Succeed())
+			defer func() {
+				Expect(cli.Delete(ctx, intent)).Should(Succeed())
+			}()
+			reconcileIntent(ctx, intent)
+
+			key := types.NamespacedName{Name: inferenceService.Name, Namespace: WorkingNamespace}
+			Expect(cli.Get(ctx, key, inferenceService)).Should(Succeed())
+			Expect(*inferenceService.Spec.Predictor.Model.StorageURI).To(Equal(storageURI))
+			Expect(inferenceService.Labels).NotTo(HaveKey(deployIntentOwnerLabel))
+		})
+	})
+})
@@ -19,12 +19,14 @@ import (
 	"context"
 	"github.com/go-logr/logr"
 	mmv1alpha1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	corev1 "k8s.io/api/core/v1"
 	k8srbacv1 "k8s.io/api/rbac/v1"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	maistrav1 "maistra.io/api/core/v1"
 	"reflect"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -34,7 +36,8 @@ import (
 )
 
 const (
-	RoleBindingName       = "prometheus-ns-access"
+	RoleBindingName = "prometheus-ns-access"
+	// OpenshiftMonitoringNS is the default namespace of the cluster monitoring stack
 	OpenshiftMonitoringNS = "openshift-monitoring"
 	// PrometheusClusterRole & MonitoringSA specified within odh-manifests
 	PrometheusClusterRole = "prometheus-ns-access"
@@ -46,8 +49,30 @@ type MonitoringReconciler struct {
 	Scheme       *runtime.Scheme
 	Log          logr.Logger
 	MonitoringNS string
+	// ClusterMonitoringNS is the namespace of the cluster monitoring stack federated by
+	// the monitoring stack's Prometheus, OpenshiftMonitoringNS when empty
+	ClusterMonitoringNS string
+	// ServiceMonitorDisabled skips the ServiceMonitor reconciliation, e.g. when the
+	// cluster does not serve the Prometheus Operator API
+	ServiceMonitorDisabled bool
+	// MeshCertsDir is the directory where the Istio certificates are mounted in the
+	// Prometheus pods. When set, the metrics of the namespaces enrolled in the Service Mesh
+	// are scraped over its mTLS, so they are not rejected by a STRICT PeerAuthentication
+	MeshCertsDir string
+	// MonitoringServiceAccounts are the service accounts of the Prometheus instances granted
+	// access to the model namespaces for the service discovery, e.g. the user workload
+	// monitoring one. The MonitoringSA of the MonitoringNS is used when empty
+	MonitoringServiceAccounts []types.NamespacedName
+	// NamespaceOnboarding keeps the RoleBinding of the namespaces labeled for model serving
+	// without ServingRuntimes, it is then removed by the NamespaceReconciler
+	NamespaceOnboarding bool
+	// ExcludeFromBackup labels the resources created by the controller to be excluded from
+	// the Velero backups
+	ExcludeFromBackup bool
 }
 
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=get;list;watch;create;update;patch;delete
+
 // RoleBindingsAreEqual checks if RoleBinding are equal, if not return false
 func RoleBindingsAreEqual(sm1 k8srbacv1.RoleBinding, sm2 k8srbacv1.RoleBinding) bool {
 	areEqual :=
@@ -57,23 +82,36 @@ func RoleBindingsAreEqual(sm1 k8srbacv1.RoleBinding, sm2 k8srbacv1.RoleBinding)
 	return areEqual
 }
 
-func buildDesiredRB(rbNS string, monitoringNS string) *k8srbacv1.RoleBinding {
+// monitoringSubjects returns the subjects of the monitoring RoleBindings: the service
+// accounts, or the MonitoringSA of the monitoring namespace when none is configured
+func monitoringSubjects(monitoringNS string, serviceAccounts []types.NamespacedName) []k8srbacv1.Subject {
+	if len(serviceAccounts) == 0 {
+		serviceAccounts = []types.NamespacedName{{Name: MonitoringSA, Namespace: monitoringNS}}
+	}
+	subjects := make([]k8srbacv1.Subject, 0, len(serviceAccounts))
+	for _, serviceAccount := range serviceAccounts {
+		subjects = append(subjects, k8srbacv1.Subject{
+			Kind:      "ServiceAccount",
+			Name:      serviceAccount.Name,
+			Namespace: serviceAccount.Namespace,
+		})
+	}
+	return subjects
+}
+
+func buildDesiredRB(rbNS string, subjects []k8srbacv1.Subject) *k8srbacv1.RoleBinding {
 	desiredRB := &k8srbacv1.RoleBinding{}
 	desiredRB.ObjectMeta = metav1.ObjectMeta{
 		Name:      RoleBindingName,
 		Namespace: rbNS,
-		Labels:    map[string]string{"opendatahub.io/managed": "true"},
+		Labels:    managedLabels(rbNS, map[string]string{"opendatahub.io/managed": "true"}),
 	}
 	desiredRB.RoleRef = k8srbacv1.RoleRef{
 		APIGroup: "rbac.authorization.k8s.io",
 		Kind:     "ClusterRole",
 		Name:     PrometheusClusterRole,
 	}
-	desiredRB.Subjects = []k8srbacv1.Subject{{
-		Kind:      "ServiceAccount",
-		Name:      MonitoringSA,
-		Namespace: monitoringNS,
-	}}
+	desiredRB.Subjects = subjects
 	return desiredRB
 }
 
@@ -95,6 +133,7 @@ func (r *MonitoringReconciler) foundRB(ctx context.Context, actualRB *k8srbacv1.
 
 // createRBIfDNE will attempt to create desiredRB if it does not exist, or is different from actualRB
 func (r *MonitoringReconciler) createRBIfDNE(ctx context.Context, exists bool, desiredRB, actualRB *k8srbacv1.RoleBinding) error {
+	excludeFromBackup(desiredRB, r.ExcludeFromBackup)
 	if !exists {
 		err := r.Create(ctx, desiredRB)
 		if err != nil {
@@ -102,12 +141,13 @@ func (r *MonitoringReconciler) createRBIfDNE(ctx context.Context, exists bool, d
 			return err
 		}
 		r.Log.Info("Created RoleBinding: " + RoleBindingName)
+		auditLog(AuditActionCreate, "RoleBinding", desiredRB, "Monitoring access granted to the Prometheus service accounts")
 		return nil
 	}
 
-	// If it does exist, and it is what we expect, do nothing
+	// If it does exist, and it is what we expect or the users took it over, do nothing
 	changed := !RoleBindingsAreEqual(*desiredRB, *actualRB)
-	if !changed {
+	if !changed || managementPaused(actualRB, r.Log) {
 		return nil
 	}
 
@@ -122,6 +162,7 @@ func (r *MonitoringReconciler) createRBIfDNE(ctx context.Context, exists bool, d
 		return err
 	}
 	r.Log.Info("Updated RoleBinding: " + RoleBindingName)
+	auditLog(AuditActionUpdate, "RoleBinding", desiredRB, "RoleBinding reverted to the desired state")
 	return nil
 }
 
@@ -134,9 +175,17 @@ func (r *MonitoringReconciler) modelMeshEnabled(ns string, labels map[string]str
 	return true
 }
 
+// clusterMonitoringNS returns the namespace of the cluster monitoring stack
+func (r *MonitoringReconciler) clusterMonitoringNS() string {
+	if r.ClusterMonitoringNS == "" {
+		return OpenshiftMonitoringNS
+	}
+	return r.ClusterMonitoringNS
+}
+
 // monitoringThisNameSpace return true if this Namespace should be monitored by monitoring stack
 func (r *MonitoringReconciler) monitoringThisNameSpace(ns string, labels map[string]string) bool {
-	if ns == OpenshiftMonitoringNS || ns == r.MonitoringNS {
+	if ns == r.clusterMonitoringNS() || ns == r.MonitoringNS {
 		return true
 	}
 	return r.modelMeshEnabled(ns, labels)
@@ -167,7 +216,7 @@ func (r *MonitoringReconciler) reconcileRoleBinding(ctx context.Context, req ctr
 		if err != nil {
 			return err
 		}
-		desiredRB := buildDesiredRB(req.Namespace, r.MonitoringNS)
+		desiredRB := buildDesiredRB(req.Namespace, monitoringSubjects(r.MonitoringNS, r.MonitoringServiceAccounts))
 		err = r.createRBIfDNE(ctx, roleBindingExists, desiredRB, actualRB)
 		if err != nil {
 			return err
@@ -191,6 +240,14 @@ func (r *MonitoringReconciler) reconcileRoleBinding(ctx context.Context, req ctr
 		}
 	}
 
+	// Scrape the ModelMesh metrics of the Serving Runtimes in this NS
+	if !r.ServiceMonitorDisabled {
+		err = r.reconcileServiceMonitor(ctx, req.Namespace, noServingRuntimes)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Fetch RoleBinding in this Namespace
 	actualRB := &k8srbacv1.RoleBinding{}
 	roleBindingExists, err := r.foundRB(ctx, actualRB, req.Namespace)
@@ -199,20 +256,21 @@ func (r *MonitoringReconciler) reconcileRoleBinding(ctx context.Context, req ctr
 	}
 
 	// If there are no ServingRuntimes in this NS, remove RB
-	if noServingRuntimes {
-		if roleBindingExists {
+	if noServingRuntimes && !r.NamespaceOnboarding {
+		if roleBindingExists && !managementPaused(actualRB, log) {
 			err := r.Delete(ctx, actualRB)
 			if err != nil {
 				log.Error(err, "Failed to delete monitoring Rolebinding"+RoleBindingName)
 				return err
 			}
 			log.Info("No Serving Runtimes detected in this namespace, deleted monitoring RoleBinding : " + RoleBindingName)
+			auditLog(AuditActionDelete, "RoleBinding", actualRB, "No Serving Runtimes left in the namespace")
 		}
 		return nil
 	}
 
 	// The RoleBinding we expect to exist in this NS
-	desiredRB := buildDesiredRB(req.Namespace, r.MonitoringNS)
+	desiredRB := buildDesiredRB(req.Namespace, monitoringSubjects(r.MonitoringNS, r.MonitoringServiceAccounts))
 
 	// If it does not exist create it
 	err = r.createRBIfDNE(ctx, roleBindingExists, desiredRB, actualRB)
@@ -292,6 +350,38 @@ func (r *MonitoringReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
 				return reconcileRequests
 			}))
+	if !r.ServiceMonitorDisabled {
+		// Watch for the ServiceMonitor in modelmesh enabled namespaces
+		builder = builder.Watches(&source.Kind{Type: &monitoringv1.ServiceMonitor{}},
+			handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+				if o.GetName() != ServiceMonitorName || !checkOpenDataHubLabel(o.GetLabels()) {
+					return []reconcile.Request{}
+				}
+				r.Log.Info("Reconcile event triggered by ServiceMonitor: " + o.GetName())
+
+				namespacedName := types.NamespacedName{
+					Name:      o.GetName(),
+					Namespace: o.GetNamespace(),
+				}
+				return []reconcile.Request{{NamespacedName: namespacedName}}
+			}))
+		if r.MeshCertsDir != "" {
+			// The scrape TLS configuration follows the enrollment of the namespace in the mesh
+			builder = builder.Watches(&source.Kind{Type: &maistrav1.ServiceMeshMember{}},
+				handler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+					if o.GetName() != serviceMeshMemberName {
+						return []reconcile.Request{}
+					}
+					r.Log.Info("Reconcile event triggered by ServiceMeshMember: " + o.GetName())
+
+					namespacedName := types.NamespacedName{
+						Name:      o.GetName(),
+						Namespace: o.GetNamespace(),
+					}
+					return []reconcile.Request{{NamespacedName: namespacedName}}
+				}))
+		}
+	}
 	err := builder.Complete(r)
 	if err != nil {
 		return err
@@ -23,10 +23,12 @@ import (
 	mf "github.com/manifestival/manifestival"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	corev1 "k8s.io/api/core/v1"
 	k8srbacv1 "k8s.io/api/rbac/v1"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"time"
 )
 
 func deployServingRuntime(path string, opts mf.Option, ctx context.Context) {
@@ -109,5 +111,50 @@ var _ = Describe("ODH Controller's Monitoring Controller", func() {
 				}
 			}, timeout, interval).ShouldNot(HaveOccurred())
 		})
+
+		It("Should manage the ModelMesh ServiceMonitor", func() {
+
+			By("create a ServiceMonitor if a Serving Runtime exists.")
+
+			deployServingRuntime(ServingRuntimePath1, opts, ctx)
+
+			expectedSM := buildDesiredSM(WorkingNamespace, "")
+			actualSM := &monitoringv1.ServiceMonitor{}
+			Eventually(func() error {
+				namespacedNamed := types.NamespacedName{Name: ServiceMonitorName, Namespace: WorkingNamespace}
+				return cli.Get(ctx, namespacedNamed, actualSM)
+			}, timeout, interval).ShouldNot(HaveOccurred())
+
+			Expect(ServiceMonitorsAreEqual(*expectedSM, *actualSM)).Should(BeTrue())
+
+			By("remove the ServiceMonitor if no Serving Runtime exists.")
+
+			deleteServingRuntime(ServingRuntimePath1, opts, ctx)
+			Eventually(func() error {
+				namespacedNamed := types.NamespacedName{Name: ServiceMonitorName, Namespace: WorkingNamespace}
+				err := cli.Get(ctx, namespacedNamed, actualSM)
+				if apierrs.IsNotFound(err) {
+					return nil
+				} else {
+					return errors.New("ServiceMonitor Deletion not detected")
+				}
+			}, timeout, interval).ShouldNot(HaveOccurred())
+		})
+
+		It("Should not modify nor delete the ServiceMonitor created by the users", func() {
+			userSM := buildDesiredSM(WorkingNamespace, "")
+			userSM.Labels = map[string]string{"app": "custom-monitoring"}
+			Expect(cli.Create(ctx, userSM)).Should(Succeed())
+
+			deployServingRuntime(ServingRuntimePath1, opts, ctx)
+			deleteServingRuntime(ServingRuntimePath1, opts, ctx)
+
+			actualSM := &monitoringv1.ServiceMonitor{}
+			namespacedNamed := types.NamespacedName{Name: ServiceMonitorName, Namespace: WorkingNamespace}
+			Consistently(func() (map[string]string, error) {
+				err := cli.Get(ctx, namespacedNamed, actualSM)
+				return actualSM.Labels, err
+			}, 3*time.Second, 100*time.Millisecond).Should(Equal(userSM.Labels))
+		})
 	})
 })
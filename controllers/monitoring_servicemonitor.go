@@ -0,0 +1,181 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"path"
+	"reflect"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	maistrav1 "maistra.io/api/core/v1"
+)
+
+const (
+	ServiceMonitorName = "modelmesh-metrics-monitor"
+	// modelmeshMetricsPortName is the name of the port serving the modelmesh_* metrics
+	// in the Service created by the modelmesh-serving controller
+	modelmeshMetricsPortName = "prometheus"
+	// The files of the Istio certificates in the MeshCertsDir of the Prometheus pods, named
+	// as in the istio-certs volume of the Istio sidecars
+	meshCAFile   = "root-cert.pem"
+	meshCertFile = "cert-chain.pem"
+	meshKeyFile  = "key.pem"
+)
+
+// ServiceMonitorsAreEqual checks if ServiceMonitors are equal, if not return false
+func ServiceMonitorsAreEqual(sm1 monitoringv1.ServiceMonitor, sm2 monitoringv1.ServiceMonitor) bool {
+	return reflect.DeepEqual(sm1.ObjectMeta.Labels, sm2.ObjectMeta.Labels) &&
+		reflect.DeepEqual(sm1.Spec, sm2.Spec)
+}
+
+// buildDesiredSM defines the ServiceMonitor of the namespace. When the meshCertsDir is set,
+// the metrics are scraped over the Service Mesh mTLS with the Istio certificates mounted
+// in that directory of the Prometheus pods
+func buildDesiredSM(smNS string, meshCertsDir string) *monitoringv1.ServiceMonitor {
+	desiredSM := &monitoringv1.ServiceMonitor{}
+	desiredSM.ObjectMeta = metav1.ObjectMeta{
+		Name:      ServiceMonitorName,
+		Namespace: smNS,
+		Labels:    managedLabels(smNS, map[string]string{"opendatahub.io/managed": "true"}),
+	}
+	desiredSM.Spec = monitoringv1.ServiceMonitorSpec{
+		Selector: metav1.LabelSelector{
+			MatchLabels: map[string]string{"modelmesh-service": modelmeshServiceName},
+		},
+		Endpoints: []monitoringv1.Endpoint{{
+			Port:   modelmeshMetricsPortName,
+			Scheme: "https",
+			// ModelMesh serves its metrics with a self-signed certificate
+			TLSConfig: &monitoringv1.TLSConfig{
+				SafeTLSConfig: monitoringv1.SafeTLSConfig{
+					InsecureSkipVerify: true,
+				},
+			},
+		}},
+	}
+	if meshCertsDir != "" {
+		// The Istio certificates are issued to SPIFFE identities, not to the Service host
+		desiredSM.Spec.Endpoints[0].TLSConfig = &monitoringv1.TLSConfig{
+			SafeTLSConfig: monitoringv1.SafeTLSConfig{
+				InsecureSkipVerify: true,
+			},
+			CAFile:   path.Join(meshCertsDir, meshCAFile),
+			CertFile: path.Join(meshCertsDir, meshCertFile),
+			KeyFile:  path.Join(meshCertsDir, meshKeyFile),
+		}
+	}
+	return desiredSM
+}
+
+// meshCertsDir returns the directory of the Istio certificates used to scrape the namespace,
+// or an empty string when the namespace is not enrolled in the Service Mesh
+func (r *MonitoringReconciler) meshCertsDir(ctx context.Context, ns string) (string, error) {
+	if r.MeshCertsDir == "" {
+		return "", nil
+	}
+	meshMember := &maistrav1.ServiceMeshMember{}
+	err := r.Get(ctx, types.NamespacedName{Name: serviceMeshMemberName, Namespace: ns}, meshMember)
+	if apierrs.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return r.MeshCertsDir, nil
+}
+
+// foundSM stores the modelmesh ServiceMonitor in actualSM if it is found in ns namespace
+func (r *MonitoringReconciler) foundSM(ctx context.Context, actualSM *monitoringv1.ServiceMonitor, ns string) (bool, error) {
+	namespacedName := types.NamespacedName{
+		Name:      ServiceMonitorName,
+		Namespace: ns,
+	}
+	err := r.Client.Get(ctx, namespacedName, actualSM)
+	if apierrs.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		r.Log.Error(err, "Failed to get ServiceMonitor "+ServiceMonitorName)
+		return false, err
+	}
+	return true, nil
+}
+
+// createSMIfDNE will attempt to create desiredSM if it does not exist, or is different from actualSM
+func (r *MonitoringReconciler) createSMIfDNE(ctx context.Context, exists bool, desiredSM, actualSM *monitoringv1.ServiceMonitor) error {
+	if !exists {
+		err := r.Create(ctx, desiredSM)
+		if err != nil {
+			r.Log.Error(err, "Failed to create ServiceMonitor "+ServiceMonitorName)
+			return err
+		}
+		r.Log.Info("Created ServiceMonitor: " + ServiceMonitorName)
+		return nil
+	}
+
+	// If it does exist, and it is what we expect, the users took it over or created it, do nothing
+	if !checkOpenDataHubLabel(actualSM.Labels) || managementPaused(actualSM, r.Log) ||
+		ServiceMonitorsAreEqual(*desiredSM, *actualSM) {
+		return nil
+	}
+
+	// If it does exist but the ServiceMonitor has changed, revert
+	actualSM.Spec = desiredSM.Spec
+	actualSM.ObjectMeta.Labels = desiredSM.ObjectMeta.Labels
+	err := r.Client.Update(ctx, actualSM)
+	if err != nil {
+		r.Log.Error(err, "Failed to update ServiceMonitor: "+ServiceMonitorName)
+		return err
+	}
+	r.Log.Info("Updated ServiceMonitor: " + ServiceMonitorName)
+	return nil
+}
+
+// reconcileServiceMonitor creates the ServiceMonitor scraping the ModelMesh metrics of the
+// namespace when it has Serving Runtimes, and removes it otherwise
+func (r *MonitoringReconciler) reconcileServiceMonitor(ctx context.Context, ns string, noServingRuntimes bool) error {
+	log := r.Log.WithValues("Namespace", ns)
+
+	actualSM := &monitoringv1.ServiceMonitor{}
+	serviceMonitorExists, err := r.foundSM(ctx, actualSM, ns)
+	if err != nil {
+		return err
+	}
+
+	if noServingRuntimes {
+		// The ServiceMonitors with the same name created by the users are kept
+		if serviceMonitorExists && checkOpenDataHubLabel(actualSM.Labels) && !managementPaused(actualSM, log) {
+			err := r.Delete(ctx, actualSM)
+			if err != nil && !apierrs.IsNotFound(err) {
+				log.Error(err, "Failed to delete ServiceMonitor "+ServiceMonitorName)
+				return err
+			}
+			log.Info("No Serving Runtimes detected in this namespace, deleted ServiceMonitor : " + ServiceMonitorName)
+		}
+		return nil
+	}
+
+	certsDir, err := r.meshCertsDir(ctx, ns)
+	if err != nil {
+		log.Error(err, "Unable to fetch the ServiceMeshMember of the namespace")
+		return err
+	}
+	desiredSM := buildDesiredSM(ns, certsDir)
+	excludeFromBackup(desiredSM, r.ExcludeFromBackup)
+	return r.createSMIfDNE(ctx, serviceMonitorExists, desiredSM, actualSM)
+}
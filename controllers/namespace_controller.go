@@ -0,0 +1,304 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8srbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	maistrav1 "maistra.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// NamespaceReconciler provisions the baseline resources of the namespaces onboarded for
+// model serving when they are labeled, instead of creating them with their first model,
+// and removes them when the labels are removed
+type NamespaceReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Log    logr.Logger
+	// MeshDisabled skips the enrollment of the onboarded namespaces in the Service Mesh
+	MeshDisabled bool
+	// MeshControlPlane is the ServiceMeshControlPlane the namespaces are enrolled in,
+	// DefaultMeshControlPlane when empty
+	MeshControlPlane types.NamespacedName
+	// MonitoringNS is the namespace of the monitoring stack's Prometheus granted access to
+	// the onboarded namespaces, no access is provisioned when empty
+	MonitoringNS string
+	// MonitoringServiceAccounts are the service accounts granted the access, see
+	// MonitoringReconciler
+	MonitoringServiceAccounts []types.NamespacedName
+	// ExcludeFromBackup labels the resources created by the controller to be excluded from
+	// the Velero backups
+	ExcludeFromBackup bool
+}
+
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+
+// namespaceOnboarded returns true if the namespace is labeled for model serving, either
+// for ModelMesh or by the ODH dashboard
+func namespaceOnboarded(labels map[string]string) bool {
+	return labels["modelmesh-enabled"] == "true" || labels["opendatahub.io/dashboard"] == "true"
+}
+
+// reconcileStorageConfig creates an empty Storage Config Secret, its content is then
+// managed by the StorageSecretReconciler from the data connections of the namespace
+func (r *NamespaceReconciler) reconcileStorageConfig(ctx context.Context, namespace string) error {
+	foundStorageSecret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: storageSecretName, Namespace: namespace}, foundStorageSecret)
+	if err == nil {
+		return nil
+	} else if !apierrs.IsNotFound(err) {
+		r.Log.Error(err, "Unable to fetch the Storage Config Secret", "namespace", namespace)
+		return err
+	}
+
+	desiredStorageSecret := &corev1.Secret{}
+	desiredStorageSecret.Name = storageSecretName
+	desiredStorageSecret.Namespace = namespace
+	desiredStorageSecret.Labels = managedLabels(namespace, map[string]string{"opendatahub.io/managed": "true"})
+	excludeFromBackup(desiredStorageSecret, r.ExcludeFromBackup)
+	r.Log.Info("Creating Storage Config Secret", "namespace", namespace)
+	err = r.Create(ctx, desiredStorageSecret)
+	if err != nil && !apierrs.IsAlreadyExists(err) {
+		r.Log.Error(err, "Unable to create the Storage Config Secret", "namespace", namespace)
+		return err
+	}
+	auditLog(AuditActionCreate, "Secret", desiredStorageSecret, "Namespace onboarded for model serving")
+	return nil
+}
+
+// reconcileRoleBinding grants the monitoring stack's Prometheus access to the namespace.
+// The drift of the RoleBinding is reverted by the MonitoringReconciler
+func (r *NamespaceReconciler) reconcileRoleBinding(ctx context.Context, namespace string) error {
+	foundRB := &k8srbacv1.RoleBinding{}
+	err := r.Get(ctx, types.NamespacedName{Name: RoleBindingName, Namespace: namespace}, foundRB)
+	if err == nil {
+		return nil
+	} else if !apierrs.IsNotFound(err) {
+		r.Log.Error(err, "Unable to fetch the monitoring RoleBinding", "namespace", namespace)
+		return err
+	}
+
+	desiredRB := buildDesiredRB(namespace, monitoringSubjects(r.MonitoringNS, r.MonitoringServiceAccounts))
+	excludeFromBackup(desiredRB, r.ExcludeFromBackup)
+	r.Log.Info("Creating monitoring RoleBinding", "namespace", namespace)
+	err = r.Create(ctx, desiredRB)
+	if err != nil && !apierrs.IsAlreadyExists(err) {
+		r.Log.Error(err, "Unable to create the monitoring RoleBinding", "namespace", namespace)
+		return err
+	}
+	auditLog(AuditActionCreate, "RoleBinding", desiredRB, "Namespace onboarded for model serving")
+	return nil
+}
+
+// reconcileMeshMember enrolls the namespace in the Service Mesh
+func (r *NamespaceReconciler) reconcileMeshMember(ctx context.Context, namespace string) error {
+	desiredMeshMember := newNamespaceMeshMember(namespace, meshControlPlaneOrDefault(r.MeshControlPlane))
+	excludeFromBackup(desiredMeshMember, r.ExcludeFromBackup)
+	key := types.NamespacedName{Name: desiredMeshMember.Name, Namespace: namespace}
+
+	foundMeshMember := &maistrav1.ServiceMeshMember{}
+	err := r.Get(ctx, key, foundMeshMember)
+	if apierrs.IsNotFound(err) {
+		r.Log.Info("Creating ServiceMeshMember", "namespace", namespace)
+		err = r.Create(ctx, desiredMeshMember)
+		if err != nil && !apierrs.IsAlreadyExists(err) {
+			r.Log.Error(err, "Unable to create the ServiceMeshMember", "namespace", namespace)
+			return err
+		}
+		auditLog(AuditActionCreate, "ServiceMeshMember", desiredMeshMember, "Namespace enrolled in the Service Mesh")
+		return nil
+	} else if err != nil {
+		r.Log.Error(err, "Unable to fetch the ServiceMeshMember", "namespace", namespace)
+		return err
+	}
+
+	// Leave alone the MeshMembers created by the users
+	if !checkOpenDataHubLabel(foundMeshMember.Labels) {
+		r.Log.Info("ServiceMeshMember not created by the controller, leaving it untouched", "namespace", namespace)
+		return nil
+	}
+	if managementPaused(foundMeshMember, r.Log) || CompareInferenceServiceMeshMembers(desiredMeshMember, foundMeshMember) {
+		return nil
+	}
+	r.Log.Info("Reconciling ServiceMeshMember", "namespace", namespace)
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// Get the last MeshMember revision
+		if err := r.Get(ctx, key, foundMeshMember); err != nil {
+			return err
+		}
+		// Reconcile labels and spec field
+		foundMeshMember.Spec = *desiredMeshMember.Spec.DeepCopy()
+		foundMeshMember.ObjectMeta.Labels = desiredMeshMember.ObjectMeta.Labels
+		return r.Update(ctx, foundMeshMember)
+	})
+	if err != nil {
+		r.Log.Error(err, "Unable to reconcile the ServiceMeshMember", "namespace", namespace)
+		return err
+	}
+	auditLog(AuditActionUpdate, "ServiceMeshMember", foundMeshMember, "ServiceMeshMember reverted to the desired state")
+	return nil
+}
+
+// deleteManagedResource deletes the resource if it was created by the controller and has
+// not been taken over by the users
+func (r *NamespaceReconciler) deleteManagedResource(ctx context.Context, obj client.Object, kind string,
+	key types.NamespacedName) error {
+	err := r.Get(ctx, key, obj)
+	if apierrs.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		r.Log.Error(err, "Unable to fetch the "+kind, "namespace", key.Namespace)
+		return err
+	}
+	if !checkOpenDataHubLabel(obj.GetLabels()) || managementPaused(obj, r.Log) {
+		return nil
+	}
+	r.Log.Info("Deleting "+kind, "namespace", key.Namespace)
+	if err := r.Delete(ctx, obj); err != nil && !apierrs.IsNotFound(err) {
+		r.Log.Error(err, "Unable to delete the "+kind, "namespace", key.Namespace)
+		return err
+	}
+	auditLog(AuditActionDelete, kind, obj, "Namespace no longer labeled for model serving")
+	return nil
+}
+
+// teardownNamespace removes the baseline resources of a namespace no longer labeled for
+// model serving. The Storage Config Secret is kept, it holds the data connections of the
+// namespace, which are still managed by the StorageSecretReconciler
+func (r *NamespaceReconciler) teardownNamespace(ctx context.Context, namespace string) error {
+	// The monitoring namespace gets its own RoleBinding for the federation
+	if r.MonitoringNS != "" && namespace != r.MonitoringNS {
+		key := types.NamespacedName{Name: RoleBindingName, Namespace: namespace}
+		if err := r.deleteManagedResource(ctx, &k8srbacv1.RoleBinding{}, "RoleBinding", key); err != nil {
+			return err
+		}
+	}
+	if !r.MeshDisabled {
+		key := types.NamespacedName{Name: serviceMeshMemberName, Namespace: namespace}
+		if err := r.deleteManagedResource(ctx, &maistrav1.ServiceMeshMember{}, "ServiceMeshMember", key); err != nil {
+			return err
+		}
+	}
+	return r.deleteNetworkPolicies(ctx, namespace)
+}
+
+// Reconcile provisions or removes the baseline resources of the namespace according to
+// its labels
+func (r *NamespaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// Initialize logger format
+	log := r.Log.WithValues("namespace", req.Name)
+
+	ns := &corev1.Namespace{}
+	err := r.Get(ctx, types.NamespacedName{Name: req.Name}, ns)
+	if apierrs.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the Namespace")
+		return ctrl.Result{}, err
+	}
+	// The resources of a terminating namespace are deleted with it
+	if ns.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+
+	if !namespaceOnboarded(ns.Labels) {
+		log.Info("Namespace not labeled for model serving, removing its baseline resources")
+		return ctrl.Result{}, r.teardownNamespace(ctx, ns.Name)
+	}
+
+	log.Info("Onboarding namespace for model serving")
+	if err := r.reconcileStorageConfig(ctx, ns.Name); err != nil {
+		return ctrl.Result{}, err
+	}
+	if r.MonitoringNS != "" {
+		if err := r.reconcileRoleBinding(ctx, ns.Name); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if !r.MeshDisabled {
+		if err := r.reconcileMeshMember(ctx, ns.Name); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, r.reconcileNetworkPolicies(ctx, ns.Name, ns.Labels[networkProfileLabel])
+}
+
+// onboardedNamespaces filters the events of the namespaces labeled for model serving, or
+// whose labels were just removed
+func onboardedNamespaces() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return namespaceOnboarded(e.Object.GetLabels())
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return false
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return namespaceOnboarded(e.Object.GetLabels())
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return namespaceOnboarded(e.ObjectNew.GetLabels()) || namespaceOnboarded(e.ObjectOld.GetLabels())
+		},
+	}
+}
+
+// requeueNamespace returns the reconcile request of the namespace of the managed resource
+// with one of the names
+func requeueNamespace(names ...string) handler.MapFunc {
+	return func(o client.Object) []reconcile.Request {
+		if !checkOpenDataHubLabel(o.GetLabels()) {
+			return []reconcile.Request{}
+		}
+		for _, name := range names {
+			if o.GetName() == name {
+				return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: o.GetNamespace()}}}
+			}
+		}
+		return []reconcile.Request{}
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NamespaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	controllerBuilder := ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Namespace{}, builder.WithPredicates(onboardedNamespaces())).
+		Watches(&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(requeueNamespace(storageSecretName))).
+		Watches(&source.Kind{Type: &networkingv1.NetworkPolicy{}},
+			handler.EnqueueRequestsFromMapFunc(requeueNamespace(networkPolicyNames...)))
+	if !r.MeshDisabled {
+		controllerBuilder = controllerBuilder.Watches(&source.Kind{Type: &maistrav1.ServiceMeshMember{}},
+			handler.EnqueueRequestsFromMapFunc(requeueNamespace(serviceMeshMemberName)))
+	}
+	return controllerBuilder.Complete(r)
+}
@@ -0,0 +1,127 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8srbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	maistrav1 "maistra.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("The Namespace controller", func() {
+	var reconciler *NamespaceReconciler
+
+	BeforeEach(func() {
+		// The reconciler is called directly, so that the namespaces of the other tests are
+		// not onboarded
+		reconciler = &NamespaceReconciler{
+			Client:       cli,
+			Log:          ctrl.Log.WithName("controllers").WithName("namespace-controller"),
+			Scheme:       scheme.Scheme,
+			MonitoringNS: MonitoringNS,
+		}
+	})
+
+	reconcileNamespace := func(ctx context.Context, name string) {
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: name}})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	createNamespace := func(ctx context.Context, name string, labels map[string]string) *corev1.Namespace {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+		Expect(cli.Create(ctx, ns)).Should(Succeed())
+		return ns
+	}
+
+	exists := func(ctx context.Context, obj client.Object, name string, namespace string) bool {
+		err := cli.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, obj)
+		if err != nil {
+			Expect(apierrs.IsNotFound(err)).To(BeTrue())
+			return false
+		}
+		return true
+	}
+
+	Context("When a namespace is labeled for model serving", func() {
+
+		It("Should provision its baseline resources and remove them with the labels", func() {
+			ctx := context.Background()
+			ns := createNamespace(ctx, "onboarded-namespace", map[string]string{
+				"modelmesh-enabled": "true",
+				networkProfileLabel: restrictedNetworkProfile,
+			})
+			reconcileNamespace(ctx, ns.Name)
+
+			By("By checking that the baseline resources are created")
+
+			Expect(exists(ctx, &corev1.Secret{}, storageSecretName, ns.Name)).To(BeTrue())
+			Expect(exists(ctx, &k8srbacv1.RoleBinding{}, RoleBindingName, ns.Name)).To(BeTrue())
+			Expect(exists(ctx, &maistrav1.ServiceMeshMember{}, serviceMeshMemberName, ns.Name)).To(BeTrue())
+			for _, name := range networkPolicyNames {
+				Expect(exists(ctx, &networkingv1.NetworkPolicy{}, name, ns.Name)).To(BeTrue())
+			}
+
+			By("By checking that the baseline resources are removed with the labels")
+
+			ns.Labels = map[string]string{}
+			Expect(cli.Update(ctx, ns)).Should(Succeed())
+			reconcileNamespace(ctx, ns.Name)
+
+			Expect(exists(ctx, &k8srbacv1.RoleBinding{}, RoleBindingName, ns.Name)).To(BeFalse())
+			Expect(exists(ctx, &maistrav1.ServiceMeshMember{}, serviceMeshMemberName, ns.Name)).To(BeFalse())
+			for _, name := range networkPolicyNames {
+				Expect(exists(ctx, &networkingv1.NetworkPolicy{}, name, ns.Name)).To(BeFalse())
+			}
+			// The Storage Config Secret holds the data connections of the namespace
+			Expect(exists(ctx, &corev1.Secret{}, storageSecretName, ns.Name)).To(BeTrue())
+		})
+
+		It("Should leave untouched the ServiceMeshMember created by the users", func() {
+			ctx := context.Background()
+			ns := createNamespace(ctx, "user-mesh-member-namespace", nil)
+
+			meshMember := newNamespaceMeshMember(ns.Name, types.NamespacedName{Name: "user", Namespace: "user-mesh"})
+			meshMember.Labels = map[string]string{}
+			Expect(cli.Create(ctx, meshMember)).Should(Succeed())
+
+			ns.Labels = map[string]string{"modelmesh-enabled": "true"}
+			Expect(cli.Update(ctx, ns)).Should(Succeed())
+			reconcileNamespace(ctx, ns.Name)
+
+			foundMeshMember := &maistrav1.ServiceMeshMember{}
+			Expect(exists(ctx, foundMeshMember, serviceMeshMemberName, ns.Name)).To(BeTrue())
+			Expect(foundMeshMember.Spec).To(Equal(meshMember.Spec))
+			Expect(foundMeshMember.Labels).To(BeEmpty())
+
+			ns.Labels = map[string]string{}
+			Expect(cli.Update(ctx, ns)).Should(Succeed())
+			reconcileNamespace(ctx, ns.Name)
+
+			Expect(exists(ctx, &maistrav1.ServiceMeshMember{}, serviceMeshMemberName, ns.Name)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,230 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// networkProfileLabel selects the NetworkPolicies generated in the onboarded namespace,
+	// none are generated without it
+	networkProfileLabel = "serving.opendatahub.io/network-profile"
+	// restrictedNetworkProfile denies the ingress traffic of the namespace except from the
+	// namespace itself, the monitoring stack, the ingress routers, the Service Mesh gateways
+	// and TrustyAI
+	restrictedNetworkProfile = "restricted"
+
+	modelmeshGrpcPort = 8033
+
+	denyIngressNetworkPolicyName     = "odh-model-serving-deny-ingress"
+	allowNamespaceNetworkPolicyName  = "odh-model-serving-allow-same-namespace"
+	allowMonitoringNetworkPolicyName = "odh-model-serving-allow-monitoring"
+	allowRuntimeNetworkPolicyName    = "odh-model-serving-allow-runtime"
+)
+
+// networkPolicyNames are the NetworkPolicies of the restricted profile, the default deny
+// policy is created last and deleted first so the traffic is never denied without its
+// exceptions
+var networkPolicyNames = []string{
+	allowNamespaceNetworkPolicyName,
+	allowMonitoringNetworkPolicyName,
+	allowRuntimeNetworkPolicyName,
+	denyIngressNetworkPolicyName,
+}
+
+// newIngressNetworkPolicy defines a NetworkPolicy of the restricted profile, applying to the
+// ingress traffic of the selected pods
+func newIngressNetworkPolicy(name string, namespace string, podSelector metav1.LabelSelector,
+	rules []networkingv1.NetworkPolicyIngressRule) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    managedLabels(namespace, map[string]string{"opendatahub.io/managed": "true"}),
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: podSelector,
+			Ingress:     rules,
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+}
+
+// namespacePeer selects the pods of the namespace with the name
+func namespacePeer(name string) networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{
+		NamespaceSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"kubernetes.io/metadata.name": name},
+		},
+	}
+}
+
+// policyGroupPeer selects the pods of the Openshift namespaces of the policy group
+func policyGroupPeer(group string) networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{
+		NamespaceSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"network.openshift.io/policy-group": group},
+		},
+	}
+}
+
+// hostNetworkPeer selects the host network namespace, the source of the traffic of the
+// routers using the HostNetwork endpoint publishing strategy
+func hostNetworkPeer() networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{
+		NamespaceSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"policy-group.network.openshift.io/host-network": ""},
+		},
+	}
+}
+
+// NewRestrictedNetworkPolicies defines the NetworkPolicies of the restricted profile: the
+// ingress traffic is denied by default, the pods of the namespace can reach each other, the
+// monitoring stacks can scrape all the pods, and the model servers can be reached on the
+// ModelMesh ports through the routers, the gateways of the Service Mesh when the namespace
+// is enrolled in the mesh, and by the TrustyAI services
+func NewRestrictedNetworkPolicies(namespace string, monitoringNS string,
+	meshNamespace string) []*networkingv1.NetworkPolicy {
+	monitoringPeers := []networkingv1.NetworkPolicyPeer{policyGroupPeer("monitoring")}
+	if monitoringNS != "" {
+		monitoringPeers = append(monitoringPeers, namespacePeer(monitoringNS))
+	}
+
+	runtimePeers := []networkingv1.NetworkPolicyPeer{
+		policyGroupPeer("ingress"),
+		hostNetworkPeer(),
+		{
+			NamespaceSelector: &metav1.LabelSelector{},
+			PodSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app.kubernetes.io/part-of": "trustyai"},
+			},
+		},
+	}
+	if meshNamespace != "" {
+		runtimePeers = append(runtimePeers, namespacePeer(meshNamespace))
+	}
+	tcp := corev1.ProtocolTCP
+	runtimePorts := []networkingv1.NetworkPolicyPort{}
+	for _, port := range []int{modelmeshServicePort, modelmeshAuthServicePort, modelmeshGrpcPort} {
+		port := intstr.FromInt(port)
+		runtimePorts = append(runtimePorts, networkingv1.NetworkPolicyPort{Protocol: &tcp, Port: &port})
+	}
+
+	return []*networkingv1.NetworkPolicy{
+		newIngressNetworkPolicy(allowNamespaceNetworkPolicyName, namespace, metav1.LabelSelector{},
+			[]networkingv1.NetworkPolicyIngressRule{{
+				From: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}},
+			}}),
+		newIngressNetworkPolicy(allowMonitoringNetworkPolicyName, namespace, metav1.LabelSelector{},
+			[]networkingv1.NetworkPolicyIngressRule{{From: monitoringPeers}}),
+		newIngressNetworkPolicy(allowRuntimeNetworkPolicyName, namespace,
+			metav1.LabelSelector{MatchLabels: map[string]string{"modelmesh-service": modelmeshServiceName}},
+			[]networkingv1.NetworkPolicyIngressRule{{From: runtimePeers, Ports: runtimePorts}}),
+		newIngressNetworkPolicy(denyIngressNetworkPolicyName, namespace, metav1.LabelSelector{}, nil),
+	}
+}
+
+// CompareNetworkPolicies checks if two NetworkPolicies are equal, if not return false
+func CompareNetworkPolicies(np1 *networkingv1.NetworkPolicy, np2 *networkingv1.NetworkPolicy) bool {
+	return reflect.DeepEqual(np1.ObjectMeta.Labels, np2.ObjectMeta.Labels) &&
+		reflect.DeepEqual(np1.Spec, np2.Spec)
+}
+
+// reconcileNetworkPolicy creates, or reverts the drift of, a NetworkPolicy of the profile
+func (r *NamespaceReconciler) reconcileNetworkPolicy(ctx context.Context, desiredNP *networkingv1.NetworkPolicy) error {
+	excludeFromBackup(desiredNP, r.ExcludeFromBackup)
+	key := types.NamespacedName{Name: desiredNP.Name, Namespace: desiredNP.Namespace}
+
+	foundNP := &networkingv1.NetworkPolicy{}
+	err := r.Get(ctx, key, foundNP)
+	if apierrs.IsNotFound(err) {
+		r.Log.Info("Creating NetworkPolicy "+desiredNP.Name, "namespace", desiredNP.Namespace)
+		err = r.Create(ctx, desiredNP)
+		if err != nil && !apierrs.IsAlreadyExists(err) {
+			r.Log.Error(err, "Unable to create the NetworkPolicy "+desiredNP.Name, "namespace", desiredNP.Namespace)
+			return err
+		}
+		auditLog(AuditActionCreate, "NetworkPolicy", desiredNP, "Restricted network profile applied")
+		return nil
+	} else if err != nil {
+		r.Log.Error(err, "Unable to fetch the NetworkPolicy "+desiredNP.Name, "namespace", desiredNP.Namespace)
+		return err
+	}
+
+	if managementPaused(foundNP, r.Log) || CompareNetworkPolicies(desiredNP, foundNP) {
+		return nil
+	}
+	r.Log.Info("Reconciling NetworkPolicy "+desiredNP.Name, "namespace", desiredNP.Namespace)
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// Get the last NetworkPolicy revision
+		if err := r.Get(ctx, key, foundNP); err != nil {
+			return err
+		}
+		// Reconcile labels and spec field
+		foundNP.Spec = *desiredNP.Spec.DeepCopy()
+		foundNP.ObjectMeta.Labels = desiredNP.ObjectMeta.Labels
+		return r.Update(ctx, foundNP)
+	})
+	if err != nil {
+		r.Log.Error(err, "Unable to reconcile the NetworkPolicy "+desiredNP.Name, "namespace", desiredNP.Namespace)
+		return err
+	}
+	auditLog(AuditActionUpdate, "NetworkPolicy", foundNP, "NetworkPolicy reverted to the desired state")
+	return nil
+}
+
+// reconcileNetworkPolicies applies the network profile selected by the label of the
+// namespace, the NetworkPolicies are removed when the label is removed
+func (r *NamespaceReconciler) reconcileNetworkPolicies(ctx context.Context, namespace string, profile string) error {
+	if profile != restrictedNetworkProfile {
+		if profile != "" {
+			r.Log.Info("Unknown network profile "+profile+", no NetworkPolicy is applied", "namespace", namespace)
+		}
+		return r.deleteNetworkPolicies(ctx, namespace)
+	}
+
+	meshNamespace := ""
+	if !r.MeshDisabled {
+		meshNamespace = meshControlPlaneOrDefault(r.MeshControlPlane).Namespace
+	}
+	for _, desiredNP := range NewRestrictedNetworkPolicies(namespace, r.MonitoringNS, meshNamespace) {
+		if err := r.reconcileNetworkPolicy(ctx, desiredNP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteNetworkPolicies removes the NetworkPolicies of the network profile
+func (r *NamespaceReconciler) deleteNetworkPolicies(ctx context.Context, namespace string) error {
+	for i := len(networkPolicyNames) - 1; i >= 0; i-- {
+		key := types.NamespacedName{Name: networkPolicyNames[i], Namespace: namespace}
+		if err := r.deleteManagedResource(ctx, &networkingv1.NetworkPolicy{}, "NetworkPolicy", key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
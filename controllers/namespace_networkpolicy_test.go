@@ -0,0 +1,88 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// policyByName returns the NetworkPolicy with the name, nil when there is none
+func policyByName(policies []*networkingv1.NetworkPolicy, name string) *networkingv1.NetworkPolicy {
+	for _, policy := range policies {
+		if policy.Name == name {
+			return policy
+		}
+	}
+	return nil
+}
+
+var _ = Describe("The restricted network profile", func() {
+
+	It("Should deny the ingress traffic last, after its exceptions", func() {
+		policies := NewRestrictedNetworkPolicies("models", "", "")
+		names := []string{}
+		for _, policy := range policies {
+			names = append(names, policy.Name)
+			Expect(policy.Namespace).To(Equal("models"))
+			Expect(checkOpenDataHubLabel(policy.Labels)).To(BeTrue())
+			Expect(policy.Spec.PolicyTypes).To(Equal([]networkingv1.PolicyType{networkingv1.PolicyTypeIngress}))
+		}
+		Expect(names).To(Equal(networkPolicyNames))
+
+		denyPolicy := policyByName(policies, denyIngressNetworkPolicyName)
+		Expect(denyPolicy.Spec.PodSelector.MatchLabels).To(BeEmpty())
+		Expect(denyPolicy.Spec.Ingress).To(BeEmpty())
+	})
+
+	It("Should allow the monitoring namespace to scrape the pods", func() {
+		policy := policyByName(NewRestrictedNetworkPolicies("models", "", ""), allowMonitoringNetworkPolicyName)
+		Expect(policy.Spec.Ingress).To(Equal([]networkingv1.NetworkPolicyIngressRule{
+			{From: []networkingv1.NetworkPolicyPeer{policyGroupPeer("monitoring")}},
+		}))
+
+		policy = policyByName(NewRestrictedNetworkPolicies("models", MonitoringNS, ""), allowMonitoringNetworkPolicyName)
+		Expect(policy.Spec.Ingress).To(Equal([]networkingv1.NetworkPolicyIngressRule{
+			{From: []networkingv1.NetworkPolicyPeer{policyGroupPeer("monitoring"), namespacePeer(MonitoringNS)}},
+		}))
+	})
+
+	It("Should only open the ModelMesh ports of the model servers", func() {
+		policy := policyByName(NewRestrictedNetworkPolicies("models", "", ""), allowRuntimeNetworkPolicyName)
+		Expect(policy.Spec.PodSelector.MatchLabels).To(Equal(map[string]string{"modelmesh-service": modelmeshServiceName}))
+		Expect(policy.Spec.Ingress).To(HaveLen(1))
+
+		ports := []intstr.IntOrString{}
+		for _, port := range policy.Spec.Ingress[0].Ports {
+			Expect(string(*port.Protocol)).To(Equal("TCP"))
+			ports = append(ports, *port.Port)
+		}
+		Expect(ports).To(ConsistOf(intstr.FromInt(modelmeshServicePort), intstr.FromInt(modelmeshAuthServicePort),
+			intstr.FromInt(modelmeshGrpcPort)))
+		Expect(policy.Spec.Ingress[0].From).To(HaveLen(3))
+		Expect(policy.Spec.Ingress[0].From).To(ContainElement(policyGroupPeer("ingress")))
+		Expect(policy.Spec.Ingress[0].From).To(ContainElement(hostNetworkPeer()))
+		Expect(policy.Spec.Ingress[0].From).NotTo(ContainElement(namespacePeer("istio-system")))
+
+		By("By checking that the Service Mesh gateways are allowed when the namespace is in the mesh")
+
+		policy = policyByName(NewRestrictedNetworkPolicies("models", "", "istio-system"), allowRuntimeNetworkPolicyName)
+		Expect(policy.Spec.Ingress[0].From).To(HaveLen(4))
+		Expect(policy.Spec.Ingress[0].From).To(ContainElement(namespacePeer("istio-system")))
+	})
+})
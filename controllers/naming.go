@@ -0,0 +1,105 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// maxNameLength is the length of a DNS label, the names of the generated resources are
+	// kept under it since they may end up in host names
+	maxNameLength = 63
+	// nameHashLength is the number of hexadecimal characters of the hash of truncated names
+	nameHashLength = 8
+)
+
+// truncateName returns the name when it fits in maxLength characters, otherwise it is
+// truncated and suffixed with a hash of the full name, so that distinct names remain distinct.
+// The name is replaced by the hash, cut to maxLength, when there is no room for a prefix
+func truncateName(name string, maxLength int) string {
+	if len(name) <= maxLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:nameHashLength]
+	if maxLength < 1 {
+		maxLength = 1
+	}
+	if maxLength <= nameHashLength+1 {
+		return hash[:maxLength]
+	}
+	prefix := strings.TrimRight(name[:maxLength-nameHashLength-1], "-.")
+	return prefix + "-" + hash
+}
+
+// childName returns the name of a resource derived from the parent name and the suffix,
+// e.g. "<isvc>-slo", shortened to fit in a DNS label
+func childName(parent string, suffix string) string {
+	return truncateName(parent, maxNameLength-len(suffix)-1) + "-" + suffix
+}
+
+// inferenceServiceLabelValue returns the value of the inferenceservice-name label of the
+// resources of the InferenceService, shortened to fit in a label value
+func inferenceServiceLabelValue(inferenceservice *inferenceservicev1.InferenceService) string {
+	return truncateName(inferenceservice.Name, maxNameLength)
+}
+
+// checkNameCollision returns an error if the existing resource holding the name belongs to
+// another InferenceService, which can only happen when truncated names collide
+func checkNameCollision(obj client.Object, inferenceservice *inferenceservicev1.InferenceService) error {
+	owner, ok := obj.GetLabels()["inferenceservice-name"]
+	if ok && owner != inferenceServiceLabelValue(inferenceservice) {
+		return fmt.Errorf("the name %s is already used by the resources of the InferenceService %s",
+			obj.GetName(), owner)
+	}
+	return nil
+}
+
+// deleteLegacyResource removes the resource created for the InferenceService under the name
+// used before the names were shortened, once it has been replaced
+func (r *OpenshiftInferenceServiceReconciler) deleteLegacyResource(ctx context.Context, obj client.Object,
+	legacyName string, inferenceservice *inferenceservicev1.InferenceService) error {
+	if legacyName == obj.GetName() {
+		return nil
+	}
+	legacy := obj.DeepCopyObject().(client.Object)
+	err := r.Get(ctx, types.NamespacedName{Name: legacyName, Namespace: inferenceservice.Namespace}, legacy)
+	if apierrs.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	// Only the resources controlled by the InferenceService are migrated
+	if !metav1.IsControlledBy(legacy, inferenceservice) {
+		return nil
+	}
+	r.Log.Info("Deleting resource with legacy name", "name", legacyName, "namespace", inferenceservice.Namespace)
+	if err := r.Delete(ctx, legacy); err != nil && !apierrs.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
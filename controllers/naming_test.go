@@ -0,0 +1,93 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const longModelName = "model-with-a-very-long-name-that-does-not-fit-in-a-dns-label-at-all"
+
+var _ = Describe("The generated resource names", func() {
+
+	DescribeTable("Should fit in the maximum length",
+		func(name string, maxLength int, expected string) {
+			Expect(truncateName(name, maxLength)).To(Equal(expected))
+		},
+		Entry("when the name fits", "my-model", maxNameLength, "my-model"),
+		Entry("when the name has the maximum length", "my-model", 8, "my-model"),
+		Entry("when the name is truncated with the hash suffix", longModelName, 30,
+			"model-with-a-very-lon-84336dc4"),
+		Entry("when the truncated name ends with a dash", longModelName, 27, "model-with-a-very-84336dc4"),
+		Entry("when there is no room for a prefix", "my-model-", 5, "422f5"),
+		Entry("when the maximum length is not positive", "my-model-", -3, "4"),
+	)
+
+	DescribeTable("Should suffix the name of the parent",
+		func(parent string, suffix string, expected string) {
+			name := childName(parent, suffix)
+			Expect(name).To(Equal(expected))
+			Expect(len(name)).To(BeNumerically("<=", maxNameLength))
+		},
+		Entry("when the parent name is short", "my-model", "slo", "my-model-slo"),
+		Entry("when the parent name is truncated", strings.Repeat("a", 70), "slo",
+			strings.Repeat("a", 50)+"-6bd5e503-slo"),
+	)
+
+	It("Should keep the host label generated by the router in a DNS label", func() {
+		inferenceService := &inferenceservicev1.InferenceService{
+			ObjectMeta: metav1.ObjectMeta{Name: longModelName, Namespace: strings.Repeat("n", 54)},
+		}
+		name := inferenceServiceRouteName(inferenceService)
+		Expect(name).To(Equal("84336dc4"))
+		Expect(len(name + "-" + inferenceService.Namespace)).To(BeNumerically("<=", maxNameLength))
+		Expect(generatedHostFits(inferenceService.Namespace)).To(BeTrue())
+		Expect(generatedHostFits(strings.Repeat("n", 62))).To(BeFalse())
+	})
+
+	It("Should label the resources of an InferenceService with a valid label value", func() {
+		inferenceService := &inferenceservicev1.InferenceService{
+			ObjectMeta: metav1.ObjectMeta{Name: longModelName, Namespace: WorkingNamespace},
+		}
+		value := inferenceServiceLabelValue(inferenceService)
+		Expect(validation.IsValidLabelValue(value)).To(BeEmpty())
+
+		for _, labels := range []map[string]string{
+			NewInferenceServiceRoute(inferenceService, false).Labels,
+			NewInferenceServiceIngress(inferenceService, false, "nginx").Labels,
+			NewInferenceServiceMaintenanceService(inferenceService).Labels,
+		} {
+			Expect(labels).To(HaveKeyWithValue("inferenceservice-name", value))
+			for _, labelValue := range labels {
+				Expect(validation.IsValidLabelValue(labelValue)).To(BeEmpty())
+			}
+		}
+
+		By("By checking that the resources of the InferenceService are told apart from the other ones")
+
+		route := NewInferenceServiceRoute(inferenceService, false)
+		Expect(checkNameCollision(route, inferenceService)).To(Succeed())
+		route.Labels["inferenceservice-name"] = "other-model"
+		Expect(checkNameCollision(route, inferenceService)).NotTo(Succeed())
+	})
+})
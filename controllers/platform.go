@@ -0,0 +1,165 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// managedState is the management state of the components of the ODH operator deploying them
+	managedState = "Managed"
+	// removedState is the management state of the components the ODH operator removed
+	removedState = "Removed"
+)
+
+// dscInitializationGVK is the DSCInitialization API of the ODH operator, used through
+// unstructured objects to avoid depending on the operator module
+var dscInitializationGVK = schema.GroupVersionKind{
+	Group:   "dscinitialization.opendatahub.io",
+	Version: "v1",
+	Kind:    "DSCInitialization",
+}
+
+// DefaultMeshControlPlane is the ServiceMeshControlPlane the namespaces are enrolled in when
+// the platform does not configure it
+var DefaultMeshControlPlane = types.NamespacedName{Name: "odh", Namespace: "istio-system"}
+
+// meshControlPlaneOrDefault returns the control plane, DefaultMeshControlPlane when empty
+func meshControlPlaneOrDefault(controlPlane types.NamespacedName) types.NamespacedName {
+	if controlPlane.Name == "" || controlPlane.Namespace == "" {
+		return DefaultMeshControlPlane
+	}
+	return controlPlane
+}
+
+// PlatformConfig is the configuration of the controllers set by the DSCInitialization of the
+// ODH operator
+type PlatformConfig struct {
+	// ApplicationsNS is the namespace of the ODH applications, e.g. the dashboard
+	ApplicationsNS string
+	// MonitoringNS is the namespace of the monitoring stack, empty when it is not managed
+	MonitoringNS string
+	// MeshRemoved is true when the Service Mesh has been removed by the operator
+	MeshRemoved bool
+	// MeshControlPlane is the ServiceMeshControlPlane of the Service Mesh
+	MeshControlPlane types.NamespacedName
+}
+
+// newPlatformConfig converts the unstructured DSCInitialization
+func newPlatformConfig(obj *unstructured.Unstructured) (*PlatformConfig, error) {
+	field := func(fields ...string) (string, error) {
+		value, _, err := unstructured.NestedString(obj.Object, append([]string{"spec"}, fields...)...)
+		return value, err
+	}
+	config := &PlatformConfig{}
+	var err error
+	if config.ApplicationsNS, err = field("applicationsNamespace"); err != nil {
+		return nil, err
+	}
+	monitoringState, err := field("monitoring", "managementState")
+	if err != nil {
+		return nil, err
+	}
+	if monitoringState == managedState {
+		if config.MonitoringNS, err = field("monitoring", "namespace"); err != nil {
+			return nil, err
+		}
+	}
+	meshState, err := field("serviceMesh", "managementState")
+	if err != nil {
+		return nil, err
+	}
+	config.MeshRemoved = meshState == removedState
+	if config.MeshControlPlane.Name, err = field("serviceMesh", "controlPlane", "name"); err != nil {
+		return nil, err
+	}
+	if config.MeshControlPlane.Namespace, err = field("serviceMesh", "controlPlane", "namespace"); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// ReadPlatformConfig returns the configuration of the DSCInitialization of the cluster, nil
+// when there is none
+func ReadPlatformConfig(ctx context.Context, reader client.Reader) (*PlatformConfig, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(dscInitializationGVK.GroupVersion().WithKind(dscInitializationGVK.Kind + "List"))
+	if err := reader.List(ctx, list); err != nil {
+		return nil, err
+	}
+	// The DSCInitialization is a singleton
+	if len(list.Items) == 0 {
+		return nil, nil
+	}
+	return newPlatformConfig(&list.Items[0])
+}
+
+// PlatformConfigReconciler watches the DSCInitialization and stops the manager when its
+// configuration differs from the one the controllers were set up with, so that they are
+// set up again with the new one
+type PlatformConfigReconciler struct {
+	client.Client
+	Log logr.Logger
+	// Config is the configuration the controllers were set up with, nil when there was no
+	// DSCInitialization
+	Config *PlatformConfig
+	// Stop stops the manager running the controllers
+	Stop    context.CancelFunc
+	changed int32
+}
+
+// +kubebuilder:rbac:groups=dscinitialization.opendatahub.io,resources=dscinitializations,verbs=get;list;watch
+
+// Reconcile compares the configuration of the DSCInitialization with the current one
+func (r *PlatformConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	config, err := ReadPlatformConfig(ctx, r.Client)
+	if err != nil {
+		r.Log.Error(err, "Unable to read the DSCInitialization")
+		return ctrl.Result{}, err
+	}
+	if reflect.DeepEqual(config, r.Config) {
+		return ctrl.Result{}, nil
+	}
+	r.Log.Info("Platform configuration changed, setting up the controllers again", "DSCInitialization", req.Name)
+	atomic.StoreInt32(&r.changed, 1)
+	r.Stop()
+	return ctrl.Result{}, nil
+}
+
+// Changed returns true if the manager was stopped because the platform configuration changed
+func (r *PlatformConfigReconciler) Changed() bool {
+	return atomic.LoadInt32(&r.changed) == 1
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PlatformConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	dscInitialization := &unstructured.Unstructured{}
+	dscInitialization.SetGroupVersionKind(dscInitializationGVK)
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("platformconfig").
+		For(dscInitialization).
+		Complete(r)
+}
@@ -0,0 +1,77 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// newDSCInitialization returns an unstructured DSCInitialization with the spec
+func newDSCInitialization(spec map[string]interface{}) *unstructured.Unstructured {
+	dscInitialization := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	dscInitialization.SetGroupVersionKind(dscInitializationGVK)
+	dscInitialization.SetName("default-dsci")
+	return dscInitialization
+}
+
+var _ = Describe("The platform configuration", func() {
+
+	DescribeTable("Should be read from the DSCInitialization",
+		func(spec map[string]interface{}, expected PlatformConfig) {
+			config, err := newPlatformConfig(newDSCInitialization(spec))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*config).To(Equal(expected))
+		},
+		Entry("when the monitoring and the Service Mesh are managed", map[string]interface{}{
+			"applicationsNamespace": "opendatahub",
+			"monitoring":            map[string]interface{}{"managementState": "Managed", "namespace": "odh-monitoring"},
+			"serviceMesh": map[string]interface{}{
+				"managementState": "Managed",
+				"controlPlane":    map[string]interface{}{"name": "data-science-smcp", "namespace": "istio-system"},
+			},
+		}, PlatformConfig{
+			ApplicationsNS:   "opendatahub",
+			MonitoringNS:     "odh-monitoring",
+			MeshControlPlane: types.NamespacedName{Name: "data-science-smcp", Namespace: "istio-system"},
+		}),
+		Entry("when the monitoring is not managed", map[string]interface{}{
+			"applicationsNamespace": "opendatahub",
+			"monitoring":            map[string]interface{}{"managementState": "Removed", "namespace": "odh-monitoring"},
+		}, PlatformConfig{ApplicationsNS: "opendatahub"}),
+		Entry("when the Service Mesh is removed", map[string]interface{}{
+			"serviceMesh": map[string]interface{}{"managementState": "Removed"},
+		}, PlatformConfig{MeshRemoved: true}),
+		Entry("when the spec is empty", map[string]interface{}{}, PlatformConfig{}),
+	)
+
+	It("Should fail when a field has an unexpected type", func() {
+		_, err := newPlatformConfig(newDSCInitialization(map[string]interface{}{
+			"serviceMesh": map[string]interface{}{"managementState": true},
+		}))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should default the Service Mesh control plane", func() {
+		Expect(meshControlPlaneOrDefault(types.NamespacedName{})).To(Equal(DefaultMeshControlPlane))
+		Expect(meshControlPlaneOrDefault(types.NamespacedName{Name: "smcp"})).To(Equal(DefaultMeshControlPlane))
+		controlPlane := types.NamespacedName{Name: "smcp", Namespace: "mesh"}
+		Expect(meshControlPlaneOrDefault(controlPlane)).To(Equal(controlPlane))
+	})
+})
@@ -0,0 +1,243 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	predictorv1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// minAvailableAnnotation overrides the number, or percentage, of the runtime pods kept
+	// available by the PodDisruptionBudget, 1 by default
+	minAvailableAnnotation = "serving.opendatahub.io/pdb-min-available"
+)
+
+// PodDisruptionBudgetReconciler protects the ModelMesh runtimes having several replicas
+// from the voluntary disruptions, e.g. the node drains of the cluster upgrades, with a
+// PodDisruptionBudget keeping some of their pods available
+type PodDisruptionBudgetReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Log    logr.Logger
+	// ExcludeFromBackup labels the resources created by the controller to be excluded from
+	// the Velero backups
+	ExcludeFromBackup bool
+}
+
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch
+// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+
+// runtimeDeploymentName returns the name of the Deployment of the ServingRuntime pods
+// created by the modelmesh-serving controller
+func runtimeDeploymentName(servingRuntime *predictorv1.ServingRuntime) string {
+	return modelmeshServiceName + "-" + servingRuntime.Name
+}
+
+// runtimeMinAvailable returns the pods of the runtime to keep available, less than its
+// replicas so that its pods can still be evicted one at a time
+func runtimeMinAvailable(servingRuntime *predictorv1.ServingRuntime, replicas int32) (intstr.IntOrString, error) {
+	value, ok := servingRuntime.Annotations[minAvailableAnnotation]
+	if !ok {
+		return intstr.FromInt(1), nil
+	}
+	minAvailable := intstr.Parse(value)
+	if minAvailable.Type == intstr.String {
+		percent, err := intstr.GetScaledValueFromIntOrPercent(&minAvailable, 100, true)
+		if err != nil || percent < 0 || percent > 100 {
+			return intstr.FromInt(1), fmt.Errorf("invalid %s annotation %q", minAvailableAnnotation, value)
+		}
+		// The percentage is rounded up to pods by the disruption controller
+		pods, _ := intstr.GetScaledValueFromIntOrPercent(&minAvailable, int(replicas), true)
+		if pods >= int(replicas) {
+			return intstr.FromInt(int(replicas - 1)), nil
+		}
+		return minAvailable, nil
+	}
+	if minAvailable.IntValue() < 0 {
+		return intstr.FromInt(1), fmt.Errorf("invalid %s annotation %q", minAvailableAnnotation, value)
+	}
+	if minAvailable.IntVal >= replicas {
+		return intstr.FromInt(int(replicas - 1)), nil
+	}
+	return minAvailable, nil
+}
+
+// NewServingRuntimePodDisruptionBudget defines the desired PodDisruptionBudget of the pods of
+// the ServingRuntime
+func NewServingRuntimePodDisruptionBudget(servingRuntime *predictorv1.ServingRuntime,
+	minAvailable intstr.IntOrString) *policyv1.PodDisruptionBudget {
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      runtimeDeploymentName(servingRuntime),
+			Namespace: servingRuntime.Namespace,
+			Labels: managedLabels(servingRuntime.Name, map[string]string{
+				"opendatahub.io/managed": "true",
+			}),
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MinAvailable: &minAvailable,
+			// The labels of the runtime pods set by the modelmesh-serving controller
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"modelmesh-service": modelmeshServiceName,
+					"name":              runtimeDeploymentName(servingRuntime),
+				},
+			},
+		},
+	}
+}
+
+// ComparePodDisruptionBudgets checks if two PodDisruptionBudgets are equal, if not return false
+func ComparePodDisruptionBudgets(pdb1 policyv1.PodDisruptionBudget, pdb2 policyv1.PodDisruptionBudget) bool {
+	// Two PodDisruptionBudgets will be equal if the labels and spec are identical
+	return reflect.DeepEqual(pdb1.ObjectMeta.Labels, pdb2.ObjectMeta.Labels) &&
+		reflect.DeepEqual(pdb1.Spec, pdb2.Spec)
+}
+
+// Reconcile creates the PodDisruptionBudget of the ServingRuntime when it has several
+// replicas, and removes it otherwise
+func (r *PodDisruptionBudgetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// Initialize logger format
+	log := r.Log.WithValues("ServingRuntime", req.Name, "namespace", req.Namespace)
+
+	servingRuntime := &predictorv1.ServingRuntime{}
+	err := r.Get(ctx, req.NamespacedName, servingRuntime)
+	if apierrs.IsNotFound(err) {
+		// The PodDisruptionBudget is deleted with its ServingRuntime
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the Serving Runtime")
+		return ctrl.Result{}, err
+	}
+
+	// The replicas of the runtime are resolved by the modelmesh-serving controller, e.g.
+	// from its default number of pods per runtime or its scale to zero
+	replicas := int32(0)
+	deployment := &appsv1.Deployment{}
+	key := types.NamespacedName{Name: runtimeDeploymentName(servingRuntime), Namespace: req.Namespace}
+	err = r.Get(ctx, key, deployment)
+	if err != nil && !apierrs.IsNotFound(err) {
+		log.Error(err, "Unable to fetch the Deployment of the Serving Runtime")
+		return ctrl.Result{}, err
+	}
+	if err == nil && deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+
+	foundPDB := &policyv1.PodDisruptionBudget{}
+	err = r.Get(ctx, key, foundPDB)
+	if err != nil && !apierrs.IsNotFound(err) {
+		log.Error(err, "Unable to fetch the PodDisruptionBudget")
+		return ctrl.Result{}, err
+	}
+	found := err == nil
+	if found && !metav1.IsControlledBy(foundPDB, servingRuntime) {
+		// The PodDisruptionBudgets with the same name created by the users are kept
+		log.Info("PodDisruptionBudget not controlled by the Serving Runtime, leaving it unmodified")
+		return ctrl.Result{}, nil
+	}
+	if found && managementPaused(foundPDB, log) {
+		return ctrl.Result{}, nil
+	}
+
+	// A single pod cannot be kept available while being evicted
+	if replicas <= 1 {
+		if found {
+			log.Info("Serving Runtime has less than 2 replicas. Deleting its PodDisruptionBudget")
+			if err := r.Delete(ctx, foundPDB); err != nil && !apierrs.IsNotFound(err) {
+				log.Error(err, "Unable to delete the PodDisruptionBudget")
+				return ctrl.Result{}, err
+			}
+			auditLog(AuditActionDelete, "PodDisruptionBudget", foundPDB, "Serving Runtime scaled down")
+		}
+		return ctrl.Result{}, nil
+	}
+
+	minAvailable, err := runtimeMinAvailable(servingRuntime, replicas)
+	if err != nil {
+		// Retrying will not fix the annotation, the default is used
+		log.Error(err, "Ignoring the minimum of available pods")
+	}
+	desiredPDB := NewServingRuntimePodDisruptionBudget(servingRuntime, minAvailable)
+	excludeFromBackup(desiredPDB, r.ExcludeFromBackup)
+
+	if !found {
+		log.Info("Creating PodDisruptionBudget")
+		// Add .metatada.ownerReferences to the PodDisruptionBudget to be deleted by the
+		// Kubernetes garbage collector if the ServingRuntime is deleted
+		if err := ctrl.SetControllerReference(servingRuntime, desiredPDB, r.Scheme); err != nil {
+			log.Error(err, "Unable to add OwnerReference to the PodDisruptionBudget")
+			return ctrl.Result{}, err
+		}
+		if err := r.Create(ctx, desiredPDB); err != nil && !apierrs.IsAlreadyExists(err) {
+			log.Error(err, "Unable to create the PodDisruptionBudget")
+			return ctrl.Result{}, err
+		}
+		auditLog(AuditActionCreate, "PodDisruptionBudget", desiredPDB, "Serving Runtime has several replicas")
+		return ctrl.Result{}, nil
+	}
+
+	// Reconcile the PodDisruptionBudget if it has been manually modified or the replicas
+	// of the runtime have changed
+	if !ComparePodDisruptionBudgets(*desiredPDB, *foundPDB) {
+		log.Info("Reconciling PodDisruptionBudget")
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			// Get the last PodDisruptionBudget revision
+			if err := r.Get(ctx, key, foundPDB); err != nil {
+				return err
+			}
+			foundPDB.Spec = *desiredPDB.Spec.DeepCopy()
+			foundPDB.ObjectMeta.Labels = desiredPDB.ObjectMeta.Labels
+			return r.Update(ctx, foundPDB)
+		})
+		if err != nil {
+			log.Error(err, "Unable to reconcile the PodDisruptionBudget")
+			return ctrl.Result{}, err
+		}
+		auditLog(AuditActionUpdate, "PodDisruptionBudget", foundPDB, "PodDisruptionBudget reverted to the desired state")
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PodDisruptionBudgetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// The ServingRuntimes are also reconciled by the MonitoringReconciler
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("poddisruptionbudget").
+		For(&predictorv1.ServingRuntime{}).
+		Owns(&policyv1.PodDisruptionBudget{}).
+		// The runtime Deployments are owned by their ServingRuntime
+		Watches(&source.Kind{Type: &appsv1.Deployment{}},
+			&handler.EnqueueRequestForOwner{OwnerType: &predictorv1.ServingRuntime{}, IsController: true}).
+		Complete(r)
+}
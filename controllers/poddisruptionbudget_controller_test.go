@@ -0,0 +1,105 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	predictorv1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
+	mfc "github.com/manifestival/controller-runtime-client"
+	mf "github.com/manifestival/manifestival"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// newMinAvailableRuntime returns a ServingRuntime with the minimum of available pods
+// annotation, none when empty
+func newMinAvailableRuntime(minAvailable string) *predictorv1.ServingRuntime {
+	servingRuntime := &predictorv1.ServingRuntime{}
+	servingRuntime.Name = "ovms"
+	if minAvailable != "" {
+		servingRuntime.Annotations = map[string]string{minAvailableAnnotation: minAvailable}
+	}
+	return servingRuntime
+}
+
+var _ = Describe("The PodDisruptionBudget controller", func() {
+
+	DescribeTable("Should keep the runtime pods evictable one at a time",
+		func(minAvailable string, replicas int32, expected intstr.IntOrString) {
+			value, err := runtimeMinAvailable(newMinAvailableRuntime(minAvailable), replicas)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value).To(Equal(expected))
+		},
+		Entry("when there is no annotation", "", int32(3), intstr.FromInt(1)),
+		Entry("when the number is less than the replicas", "2", int32(4), intstr.FromInt(2)),
+		Entry("when the number is the replicas", "3", int32(3), intstr.FromInt(2)),
+		Entry("when the number exceeds the replicas", "5", int32(3), intstr.FromInt(2)),
+		Entry("when the percentage leaves a pod evictable", "50%", int32(4), intstr.FromString("50%")),
+		Entry("when the percentage is all the pods", "100%", int32(4), intstr.FromInt(3)),
+		Entry("when the percentage is rounded up to all the pods", "60%", int32(2), intstr.FromInt(1)),
+	)
+
+	DescribeTable("Should reject the invalid annotations",
+		func(minAvailable string) {
+			value, err := runtimeMinAvailable(newMinAvailableRuntime(minAvailable), 3)
+			Expect(err).To(HaveOccurred())
+			Expect(value).To(Equal(intstr.FromInt(1)))
+		},
+		Entry("when the number is negative", "-1"),
+		Entry("when the percentage exceeds 100%", "150%"),
+		Entry("when the value is neither a number nor a percentage", "half"),
+	)
+
+	It("Should leave the PodDisruptionBudget created by the users untouched", func() {
+		ctx := context.Background()
+		servingRuntime := &predictorv1.ServingRuntime{}
+		err := convertToStructuredResource(ServingRuntimePath1, servingRuntime, mf.UseClient(mfc.NewClient(cli)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cli.Create(ctx, servingRuntime)).Should(Succeed())
+
+		minAvailable := intstr.FromInt(1)
+		userPDB := &policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Name: runtimeDeploymentName(servingRuntime), Namespace: WorkingNamespace},
+			Spec:       policyv1.PodDisruptionBudgetSpec{MinAvailable: &minAvailable},
+		}
+		Expect(cli.Create(ctx, userPDB)).Should(Succeed())
+		defer func() {
+			Expect(cli.Delete(ctx, userPDB)).Should(Succeed())
+		}()
+
+		reconciler := &PodDisruptionBudgetReconciler{
+			Client: cli,
+			Log:    ctrl.Log.WithName("controllers").WithName("poddisruptionbudget-controller"),
+			Scheme: scheme.Scheme,
+		}
+		key := types.NamespacedName{Name: servingRuntime.Name, Namespace: WorkingNamespace}
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+
+		foundPDB := &policyv1.PodDisruptionBudget{}
+		pdbKey := types.NamespacedName{Name: userPDB.Name, Namespace: WorkingNamespace}
+		Expect(cli.Get(ctx, pdbKey, foundPDB)).Should(Succeed())
+		Expect(foundPDB.Labels).To(BeEmpty())
+	})
+})
@@ -0,0 +1,157 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-logr/logr"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// managedKinds are the kinds of the resources created by the running version of the
+// controller. It must be updated when a reconciler starts or stops creating a kind
+var managedKinds = map[schema.GroupKind]bool{
+	{Group: "", Kind: "ConfigMap"}:                                   true,
+	{Group: "", Kind: "Endpoints"}:                                   true,
+	{Group: "", Kind: "Secret"}:                                      true,
+	{Group: "", Kind: "Service"}:                                     true,
+	{Group: "", Kind: "ServiceAccount"}:                              true,
+	{Group: "maistra.io", Kind: "ServiceMeshMember"}:                 true,
+	{Group: "monitoring.coreos.com", Kind: "PrometheusRule"}:         true,
+	{Group: "monitoring.coreos.com", Kind: "ServiceMonitor"}:         true,
+	{Group: "networking.k8s.io", Kind: "Ingress"}:                    true,
+	{Group: "networking.k8s.io", Kind: "NetworkPolicy"}:              true,
+	{Group: "policy", Kind: "PodDisruptionBudget"}:                   true,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}: true,
+	{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"}:        true,
+	{Group: "route.openshift.io", Kind: "Route"}:                     true,
+	{Group: "serving.kserve.io", Kind: "InferenceService"}:           true,
+	{Group: "serving.kserve.io", Kind: "ServingRuntime"}:             true,
+}
+
+// Pruner deletes, when the controller starts, the resources created by another version of
+// the controller whose kind is no longer managed by the running one, e.g. after a feature
+// was removed or the controller was downgraded. The resources of the managed kinds are
+// stamped with the running version by their reconcilers instead
+type Pruner struct {
+	// Client deletes the stale resources, Reader lists them without caching every kind
+	Client    client.Client
+	Reader    client.Reader
+	Discovery discovery.DiscoveryInterface
+	Log       logr.Logger
+}
+
+// staleSelector selects the resources created by the controller with another version,
+// including the versions predating the controllerVersionLabel
+func staleSelector() (labels.Selector, error) {
+	managedBy, err := labels.NewRequirement(managedByLabel, selection.Equals, []string{controllerName})
+	if err != nil {
+		return nil, err
+	}
+	otherVersion, err := labels.NewRequirement(controllerVersionLabel, selection.NotEquals, []string{ControllerVersion})
+	if err != nil {
+		return nil, err
+	}
+	return labels.NewSelector().Add(*managedBy, *otherVersion), nil
+}
+
+// prune deletes the stale resources of the API resource
+func (p *Pruner) prune(ctx context.Context, gv schema.GroupVersion, resource metav1.APIResource,
+	selector labels.Selector) error {
+	list := &metav1.PartialObjectMetadataList{}
+	list.SetGroupVersionKind(gv.WithKind(resource.Kind + "List"))
+	if err := p.Reader.List(ctx, list, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return err
+	}
+	for i := range list.Items {
+		obj := &list.Items[i]
+		obj.SetGroupVersionKind(gv.WithKind(resource.Kind))
+		if managementPaused(obj, p.Log) {
+			continue
+		}
+		p.Log.Info("Deleting resource of a kind no longer managed by the controller", "kind", resource.Kind,
+			"name", obj.GetName(), "namespace", obj.GetNamespace(), "version", obj.GetLabels()[controllerVersionLabel])
+		if err := p.Client.Delete(ctx, obj); err != nil && !apierrs.IsNotFound(err) {
+			return err
+		}
+		auditLog(AuditActionDelete, resource.Kind, obj, "Kind no longer managed by controller version "+ControllerVersion)
+	}
+	return nil
+}
+
+// Start prunes the stale resources of all the API resources served by the cluster. The
+// kinds the controller is not allowed to list or delete are skipped
+func (p *Pruner) Start(ctx context.Context) error {
+	selector, err := staleSelector()
+	if err != nil {
+		return err
+	}
+	resourceLists, err := p.Discovery.ServerPreferredResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		p.Log.Error(err, "Unable to discover the API resources, skipping the pruning")
+		return nil
+	}
+	for _, resourceList := range resourceLists {
+		gv, err := schema.ParseGroupVersion(resourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range resourceList.APIResources {
+			// Skip the subresources and the kinds that cannot be pruned
+			if strings.Contains(resource.Name, "/") || managedKinds[gv.WithKind(resource.Kind).GroupKind()] ||
+				!hasVerbs(resource.Verbs, "list", "delete") {
+				continue
+			}
+			err := p.prune(ctx, gv, resource, selector)
+			if apierrs.IsForbidden(err) || apierrs.IsNotFound(err) || apierrs.IsMethodNotSupported(err) {
+				continue
+			} else if err != nil {
+				p.Log.Error(err, "Unable to prune the resources", "groupVersion", gv.String(), "kind", resource.Kind)
+			}
+		}
+	}
+	return nil
+}
+
+// NeedLeaderElection returns true, only the leader prunes the resources
+func (p *Pruner) NeedLeaderElection() bool {
+	return true
+}
+
+// hasVerbs returns true if all the verbs are supported
+func hasVerbs(supported metav1.Verbs, verbs ...string) bool {
+	for _, verb := range verbs {
+		found := false
+		for _, s := range supported {
+			if s == verb {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
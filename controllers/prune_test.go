@@ -0,0 +1,109 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// preferredResourcesDiscovery serves the resources of the fake discovery as the preferred
+// ones, which the fake discovery does not
+type preferredResourcesDiscovery struct {
+	*fakediscovery.FakeDiscovery
+}
+
+func (d preferredResourcesDiscovery) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
+	return d.Resources, nil
+}
+
+// staleLabels returns the labels of a resource created by the controller version
+func staleLabels(version string) map[string]string {
+	return map[string]string{managedByLabel: controllerName, controllerVersionLabel: version}
+}
+
+var _ = Describe("The stale resources pruner", func() {
+
+	DescribeTable("Should select the resources created by another version of the controller",
+		func(resourceLabels map[string]string, expected bool) {
+			selector, err := staleSelector()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(selector.Matches(labels.Set(resourceLabels))).To(Equal(expected))
+		},
+		Entry("when the version differs", staleLabels("0.0.1"), true),
+		Entry("when the resource predates the version label", map[string]string{managedByLabel: controllerName}, true),
+		Entry("when the version is the running one", staleLabels(ControllerVersion), false),
+		Entry("when the resource is not created by the controller", map[string]string{controllerVersionLabel: "0.0.1"}, false),
+	)
+
+	It("Should only delete the stale resources of the kinds no longer managed", func() {
+		ctx := context.Background()
+		verbs := metav1.Verbs{"create", "delete", "get", "list", "update", "watch"}
+		discovery := preferredResourcesDiscovery{&fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}}
+		discovery.Resources = []*metav1.APIResourceList{{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "configmaps", Namespaced: true, Kind: "ConfigMap", Verbs: verbs},
+				{Name: "endpoints", Namespaced: true, Kind: "Endpoints", Verbs: verbs},
+				{Name: "pods/log", Namespaced: true, Kind: "Pod", Verbs: metav1.Verbs{"get"}},
+			},
+		}}
+		pruner := &Pruner{
+			Client:    cli,
+			Reader:    cli,
+			Discovery: discovery,
+			Log:       ctrl.Log.WithName("prune"),
+		}
+
+		resources := []client.Object{
+			&corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "stale-endpoints", Namespace: WorkingNamespace,
+				Labels: staleLabels("0.0.1")}},
+			&corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "current-endpoints", Namespace: WorkingNamespace,
+				Labels: staleLabels(ControllerVersion)}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "stale-configmap", Namespace: WorkingNamespace,
+				Labels: staleLabels("0.0.1")}},
+		}
+		for _, resource := range resources {
+			Expect(cli.Create(ctx, resource)).Should(Succeed())
+		}
+		defer func() {
+			for _, resource := range resources {
+				Expect(client.IgnoreNotFound(cli.Delete(ctx, resource))).Should(Succeed())
+			}
+		}()
+
+		Expect(pruner.Start(ctx)).Should(Succeed())
+
+		err := cli.Get(ctx, types.NamespacedName{Name: "stale-endpoints", Namespace: WorkingNamespace}, &corev1.Endpoints{})
+		Expect(apierrs.IsNotFound(err)).To(BeTrue())
+		Expect(cli.Get(ctx, types.NamespacedName{Name: "current-endpoints", Namespace: WorkingNamespace},
+			&corev1.Endpoints{})).Should(Succeed())
+		Expect(cli.Get(ctx, types.NamespacedName{Name: "stale-configmap", Namespace: WorkingNamespace},
+			&corev1.ConfigMap{})).Should(Succeed())
+	})
+})
@@ -0,0 +1,435 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	predictorv1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// acceleratorNameAnnotation references the AcceleratorProfile requested by the model
+	acceleratorNameAnnotation = "opendatahub.io/accelerator-name"
+	// inferenceServiceAcceleratorField indexes the InferenceServices by the name of the
+	// AcceleratorProfile they reference
+	inferenceServiceAcceleratorField = "metadata.annotations.accelerator-name"
+	// injectedAcceleratorAnnotation records the tolerations and the resources injected in the
+	// ServingRuntime from the AcceleratorProfile, so that they are removed when the profile
+	// changes or is no longer requested
+	injectedAcceleratorAnnotation = "serving.opendatahub.io/injected-accelerator"
+)
+
+// acceleratorProfileGVK is the AcceleratorProfile API of the ODH dashboard, used through
+// unstructured objects to avoid depending on the dashboard module
+var acceleratorProfileGVK = schema.GroupVersionKind{
+	Group:   "dashboard.opendatahub.io",
+	Version: "v1",
+	Kind:    "AcceleratorProfile",
+}
+
+// acceleratorProfile holds the fields of an AcceleratorProfile injected in the runtimes
+type acceleratorProfile struct {
+	Name        string
+	Enabled     bool
+	Identifier  string
+	Tolerations []corev1.Toleration
+}
+
+// newAcceleratorProfile converts the unstructured AcceleratorProfile
+func newAcceleratorProfile(obj *unstructured.Unstructured) (*acceleratorProfile, error) {
+	profile := &acceleratorProfile{Name: obj.GetName(), Enabled: true}
+	if enabled, found, err := unstructured.NestedBool(obj.Object, "spec", "enabled"); err != nil {
+		return nil, err
+	} else if found {
+		profile.Enabled = enabled
+	}
+	identifier, _, err := unstructured.NestedString(obj.Object, "spec", "identifier")
+	if err != nil {
+		return nil, err
+	}
+	profile.Identifier = identifier
+	tolerations, found, err := unstructured.NestedSlice(obj.Object, "spec", "tolerations")
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		spec := struct {
+			Tolerations []corev1.Toleration `json:"tolerations"`
+		}{}
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(
+			map[string]interface{}{"tolerations": tolerations}, &spec)
+		if err != nil {
+			return nil, err
+		}
+		profile.Tolerations = spec.Tolerations
+	}
+	return profile, nil
+}
+
+// acceleratorInjection holds the tolerations and the accelerator resources injected in
+// the ServingRuntime, recorded in the injected-accelerator annotation
+type acceleratorInjection struct {
+	Tolerations []corev1.Toleration   `json:"tolerations,omitempty"`
+	Resources   []corev1.ResourceName `json:"resources,omitempty"`
+}
+
+// injectedAccelerator returns the injection recorded in the ServingRuntime, an invalid record
+// is ignored and the tolerations and resources are then considered set by the users
+func injectedAccelerator(servingRuntime *predictorv1.ServingRuntime) acceleratorInjection {
+	injected := acceleratorInjection{}
+	if value, ok := servingRuntime.Annotations[injectedAcceleratorAnnotation]; ok {
+		_ = json.Unmarshal([]byte(value), &injected)
+	}
+	return injected
+}
+
+// containsToleration returns true if the toleration is part of the list
+func containsToleration(tolerations []corev1.Toleration, toleration corev1.Toleration) bool {
+	for _, existing := range tolerations {
+		if reflect.DeepEqual(existing, toleration) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsResourceName returns true if the resource name is part of the list
+func containsResourceName(names []corev1.ResourceName, name corev1.ResourceName) bool {
+	for _, existing := range names {
+		if existing == name {
+			return true
+		}
+	}
+	return false
+}
+
+// applyAcceleratorInjection replaces the injected tolerations and resources of the runtime
+// with the desired ones and records them, returns true if the ServingRuntime was modified.
+// The entries already set by the users are kept and not recorded, so that they are never
+// removed
+func applyAcceleratorInjection(servingRuntime *predictorv1.ServingRuntime, injected acceleratorInjection,
+	desired acceleratorInjection) bool {
+	changed := false
+	record := acceleratorInjection{}
+
+	tolerations := []corev1.Toleration{}
+	for _, existing := range servingRuntime.Spec.Tolerations {
+		if containsToleration(injected.Tolerations, existing) {
+			if !containsToleration(desired.Tolerations, existing) {
+				changed = true
+				continue
+			}
+			record.Tolerations = append(record.Tolerations, existing)
+		}
+		tolerations = append(tolerations, existing)
+	}
+	for _, toleration := range desired.Tolerations {
+		if !containsToleration(tolerations, toleration) {
+			tolerations = append(tolerations, toleration)
+			record.Tolerations = append(record.Tolerations, toleration)
+			changed = true
+		}
+	}
+	if changed {
+		servingRuntime.Spec.Tolerations = tolerations
+	}
+
+	if len(servingRuntime.Spec.Containers) > 0 {
+		resources := &servingRuntime.Spec.Containers[0].Resources
+		for _, name := range injected.Resources {
+			if containsResourceName(desired.Resources, name) {
+				continue
+			}
+			if _, ok := resources.Limits[name]; ok {
+				delete(resources.Limits, name)
+				changed = true
+			}
+			if _, ok := resources.Requests[name]; ok {
+				delete(resources.Requests, name)
+				changed = true
+			}
+		}
+		for _, name := range desired.Resources {
+			wasInjected := containsResourceName(injected.Resources, name)
+			if _, ok := resources.Limits[name]; !ok {
+				if resources.Limits == nil {
+					resources.Limits = corev1.ResourceList{}
+				}
+				resources.Limits[name] = resource.MustParse("1")
+				wasInjected = true
+				changed = true
+			}
+			if _, ok := resources.Requests[name]; !ok {
+				if resources.Requests == nil {
+					resources.Requests = corev1.ResourceList{}
+				}
+				resources.Requests[name] = resources.Limits[name]
+				changed = true
+			}
+			if wasInjected {
+				record.Resources = append(record.Resources, name)
+			}
+		}
+	}
+
+	if len(record.Tolerations) == 0 && len(record.Resources) == 0 {
+		if _, ok := servingRuntime.Annotations[injectedAcceleratorAnnotation]; ok {
+			delete(servingRuntime.Annotations, injectedAcceleratorAnnotation)
+			changed = true
+		}
+		return changed
+	}
+	value, _ := json.Marshal(record)
+	if servingRuntime.Annotations[injectedAcceleratorAnnotation] != string(value) {
+		if servingRuntime.Annotations == nil {
+			servingRuntime.Annotations = map[string]string{}
+		}
+		servingRuntime.Annotations[injectedAcceleratorAnnotation] = string(value)
+		changed = true
+	}
+	return changed
+}
+
+// injectAcceleratorProfile adds the tolerations of the profile to the runtime pods and
+// requests one accelerator for the model server container, when not already set. The
+// tolerations and resources injected from a previous profile are removed, all of them when
+// the profile is nil. Returns true if the ServingRuntime was modified
+func injectAcceleratorProfile(servingRuntime *predictorv1.ServingRuntime, profile *acceleratorProfile) bool {
+	desired := acceleratorInjection{}
+	if profile != nil {
+		desired.Tolerations = profile.Tolerations
+		if profile.Identifier != "" {
+			desired.Resources = []corev1.ResourceName{corev1.ResourceName(profile.Identifier)}
+		}
+	}
+	changed := applyAcceleratorInjection(servingRuntime, injectedAccelerator(servingRuntime), desired)
+
+	if profile == nil {
+		if _, ok := servingRuntime.Annotations[acceleratorNameAnnotation]; ok {
+			delete(servingRuntime.Annotations, acceleratorNameAnnotation)
+			changed = true
+		}
+		return changed
+	}
+	if servingRuntime.Annotations[acceleratorNameAnnotation] != profile.Name {
+		if servingRuntime.Annotations == nil {
+			servingRuntime.Annotations = map[string]string{}
+		}
+		servingRuntime.Annotations[acceleratorNameAnnotation] = profile.Name
+		changed = true
+	}
+	return changed
+}
+
+// getAcceleratorProfile fetches the profile from the namespace of the InferenceService,
+// falling back to the namespace of the cluster wide profiles
+func (r *OpenshiftInferenceServiceReconciler) getAcceleratorProfile(ctx context.Context, name string,
+	namespace string) (*acceleratorProfile, error) {
+	namespaces := []string{namespace}
+	if r.AcceleratorProfileNS != "" && r.AcceleratorProfileNS != namespace {
+		namespaces = append(namespaces, r.AcceleratorProfileNS)
+	}
+	for _, ns := range namespaces {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(acceleratorProfileGVK)
+		err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: ns}, obj)
+		if apierrs.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		return newAcceleratorProfile(obj)
+	}
+	return nil, nil
+}
+
+// autoSelectedInferenceServices returns the InferenceServices without an explicit runtime
+// that ModelMesh may deploy on the serving runtime
+func autoSelectedInferenceServices(inferenceServices []inferenceservicev1.InferenceService,
+	servingRuntimes []predictorv1.ServingRuntime, runtimeName string) []inferenceservicev1.InferenceService {
+	selected := []inferenceservicev1.InferenceService{}
+	for _, inferenceService := range inferenceServices {
+		format := inferenceServiceModelFormat(&inferenceService)
+		if format != "" && selectServingRuntime(servingRuntimes, format) == runtimeName {
+			selected = append(selected, inferenceService)
+		}
+	}
+	return selected
+}
+
+// runtimeInferenceServices returns the InferenceServices deployed on the serving runtime,
+// either explicitly or through the runtime auto-selection
+func (r *OpenshiftInferenceServiceReconciler) runtimeInferenceServices(ctx context.Context, namespace string,
+	runtimeName string) ([]inferenceservicev1.InferenceService, error) {
+	explicit := &inferenceservicev1.InferenceServiceList{}
+	err := r.List(ctx, explicit, client.InNamespace(namespace),
+		client.MatchingFields{inferenceServiceRuntimeField: runtimeName})
+	if err != nil {
+		return nil, err
+	}
+	autoSelected := &inferenceservicev1.InferenceServiceList{}
+	err = r.List(ctx, autoSelected, client.InNamespace(namespace),
+		client.MatchingFields{inferenceServiceRuntimeField: autoSelectedRuntimeIndexValue})
+	if err != nil {
+		return nil, err
+	}
+	if len(autoSelected.Items) == 0 {
+		return explicit.Items, nil
+	}
+	servingRuntimes := &predictorv1.ServingRuntimeList{}
+	if err := r.List(ctx, servingRuntimes, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	return append(explicit.Items, autoSelectedInferenceServices(autoSelected.Items, servingRuntimes.Items,
+		runtimeName)...), nil
+}
+
+// conflictingAcceleratorProfile returns the first InferenceService requesting another
+// accelerator profile than the given one, or nil if they all request it or none
+func conflictingAcceleratorProfile(inferenceServices []inferenceservicev1.InferenceService,
+	profileName string) *inferenceservicev1.InferenceService {
+	for i := range inferenceServices {
+		if name, ok := inferenceServices[i].Annotations[acceleratorNameAnnotation]; ok && name != profileName {
+			return &inferenceServices[i]
+		}
+	}
+	return nil
+}
+
+// ReconcileAcceleratorProfile will inject the tolerations and resources of the
+// AcceleratorProfile referenced by the InferenceService in its serving runtime. The
+// runtime is shared by the models deployed on it, so the profile is only injected when
+// they all reference the same one. The injected tolerations and resources are removed
+// when the profile is disabled or no longer referenced by the models of the runtime
+func (r *OpenshiftInferenceServiceReconciler) ReconcileAcceleratorProfile(
+	inferenceservice *inferenceservicev1.InferenceService, ctx context.Context) error {
+	// Initialize logger format
+	log := r.Log.WithValues("inferenceservice", inferenceservice.Name, "namespace", inferenceservice.Namespace)
+
+	profileName := inferenceservice.Annotations[acceleratorNameAnnotation]
+	runtimeName := explicitRuntimeName(inferenceservice)
+	if runtimeName == "" {
+		if profileName != "" {
+			log.Info("Serving runtime selected by ModelMesh, leaving it unmodified")
+		}
+		return nil
+	}
+	runtimeKey := types.NamespacedName{Name: runtimeName, Namespace: inferenceservice.Namespace}
+	servingRuntime := &predictorv1.ServingRuntime{}
+	err := r.Get(ctx, runtimeKey, servingRuntime)
+	if err != nil {
+		if apierrs.IsNotFound(err) {
+			return nil
+		}
+		log.Error(err, "Unable to fetch the Serving Runtime")
+		return err
+	}
+	if profileName == "" {
+		// Nothing to remove from a runtime without an injected profile
+		_, injected := servingRuntime.Annotations[injectedAcceleratorAnnotation]
+		if _, named := servingRuntime.Annotations[acceleratorNameAnnotation]; !injected && !named {
+			return nil
+		}
+	}
+
+	inferenceServices, err := r.runtimeInferenceServices(ctx, inferenceservice.Namespace, runtimeName)
+	if err != nil {
+		log.Error(err, "Unable to list the InferenceServices of the Serving Runtime")
+		return err
+	}
+	if other := conflictingAcceleratorProfile(inferenceServices, profileName); other != nil {
+		// Another model still requests a profile, it is injected when reconciling that model
+		if profileName == "" {
+			return nil
+		}
+		log.Error(fmt.Errorf("InferenceService %s requests the accelerator profile %s", other.Name,
+			other.Annotations[acceleratorNameAnnotation]),
+			"Conflicting accelerator profiles for the Serving Runtime "+runtimeName)
+		return nil
+	}
+
+	var profile *acceleratorProfile
+	if profileName != "" {
+		profile, err = r.getAcceleratorProfile(ctx, profileName, inferenceservice.Namespace)
+		if err != nil {
+			log.Error(err, "Unable to fetch the AcceleratorProfile "+profileName)
+			return err
+		}
+		if profile == nil {
+			log.Info("AcceleratorProfile " + profileName + " was not found")
+		} else if !profile.Enabled {
+			log.Info("AcceleratorProfile " + profileName + " is disabled")
+			profile = nil
+		}
+	}
+	if !injectAcceleratorProfile(servingRuntime.DeepCopy(), profile) {
+		return nil
+	}
+
+	if profile == nil {
+		log.Info("Removing the injected AcceleratorProfile from Serving Runtime " + servingRuntime.Name)
+	} else {
+		log.Info("Injecting AcceleratorProfile " + profileName + " in Serving Runtime " + servingRuntime.Name)
+	}
+	// Retry the update operation when the runtime is concurrently modified
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// Get the last serving runtime revision
+		if err := r.Get(ctx, runtimeKey, servingRuntime); err != nil {
+			return err
+		}
+		if !injectAcceleratorProfile(servingRuntime, profile) {
+			return nil
+		}
+		return r.Update(ctx, servingRuntime)
+	})
+	if err != nil {
+		log.Error(err, "Unable to inject the AcceleratorProfile in the Serving Runtime")
+		return err
+	}
+	return nil
+}
+
+// requeueAcceleratorProfileInferenceServices returns the reconcile requests of the
+// InferenceServices referencing the modified AcceleratorProfile
+func (r *OpenshiftInferenceServiceReconciler) requeueAcceleratorProfileInferenceServices(o client.Object) []reconcile.Request {
+	listOptions := []client.ListOption{}
+	// The profiles of the shared namespace are referenced from all the namespaces
+	if o.GetNamespace() != r.AcceleratorProfileNS {
+		listOptions = append(listOptions, client.InNamespace(o.GetNamespace()))
+	}
+	listOptions = append(listOptions, client.MatchingFields{inferenceServiceAcceleratorField: o.GetName()})
+	inferenceServicesList := &inferenceservicev1.InferenceServiceList{}
+	if err := r.List(context.TODO(), inferenceServicesList, listOptions...); err != nil {
+		r.Log.Info("Error getting list of inference services for accelerator profile " + o.GetName())
+		return []reconcile.Request{}
+	}
+	return inferenceServicesRequests(inferenceServicesList)
+}
@@ -0,0 +1,140 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	predictorv1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newAcceleratorInferenceService returns an InferenceService of the model format, requesting
+// the accelerator profile when not empty
+func newAcceleratorInferenceService(name string, format string, profileName string) inferenceservicev1.InferenceService {
+	inferenceService := inferenceservicev1.InferenceService{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	inferenceService.Spec.Predictor.Model = &inferenceservicev1.ModelSpec{
+		ModelFormat: inferenceservicev1.ModelFormat{Name: format},
+	}
+	if profileName != "" {
+		inferenceService.Annotations = map[string]string{acceleratorNameAnnotation: profileName}
+	}
+	return inferenceService
+}
+
+var _ = Describe("The AcceleratorProfile injection", func() {
+
+	It("Should consider the InferenceServices auto-selecting the serving runtime", func() {
+		servingRuntimes := []predictorv1.ServingRuntime{
+			newAutoSelectRuntime("ovms", "onnx", true, false),
+			newAutoSelectRuntime("triton", "tensorflow", true, false),
+		}
+		inferenceServices := []inferenceservicev1.InferenceService{
+			newAcceleratorInferenceService("onnx-model", "onnx", "nvidia-gpu"),
+			newAcceleratorInferenceService("tensorflow-model", "tensorflow", "intel-gaudi"),
+			newAcceleratorInferenceService("pytorch-model", "pytorch", "intel-gaudi"),
+		}
+
+		selected := autoSelectedInferenceServices(inferenceServices, servingRuntimes, "ovms")
+		Expect(selected).To(HaveLen(1))
+		Expect(selected[0].Name).To(Equal("onnx-model"))
+	})
+
+	It("Should not be injected when the models of the runtime request different profiles", func() {
+		inferenceServices := []inferenceservicev1.InferenceService{
+			newAcceleratorInferenceService("first-model", "onnx", "nvidia-gpu"),
+			newAcceleratorInferenceService("cpu-model", "onnx", ""),
+		}
+		Expect(conflictingAcceleratorProfile(inferenceServices, "nvidia-gpu")).To(BeNil())
+
+		inferenceServices = append(inferenceServices,
+			newAcceleratorInferenceService("auto-selected-model", "onnx", "intel-gaudi"))
+		conflicting := conflictingAcceleratorProfile(inferenceServices, "nvidia-gpu")
+		Expect(conflicting).NotTo(BeNil())
+		Expect(conflicting.Name).To(Equal("auto-selected-model"))
+	})
+
+	It("Should remove the injected tolerations and resources when the profile changes", func() {
+		userToleration := corev1.Toleration{Key: "dedicated", Operator: corev1.TolerationOpExists}
+		gpuToleration := corev1.Toleration{Key: "nvidia.com/gpu", Operator: corev1.TolerationOpExists}
+		gaudiToleration := corev1.Toleration{Key: "habana.ai/gaudi", Operator: corev1.TolerationOpExists}
+		servingRuntime := &predictorv1.ServingRuntime{}
+		servingRuntime.Spec.Tolerations = []corev1.Toleration{userToleration}
+		servingRuntime.Spec.Containers = []predictorv1.Container{{Name: "server"}}
+
+		gpuProfile := &acceleratorProfile{Name: "nvidia-gpu", Enabled: true, Identifier: "nvidia.com/gpu",
+			Tolerations: []corev1.Toleration{userToleration, gpuToleration}}
+		Expect(injectAcceleratorProfile(servingRuntime, gpuProfile)).To(BeTrue())
+		Expect(servingRuntime.Spec.Tolerations).To(Equal([]corev1.Toleration{userToleration, gpuToleration}))
+		Expect(servingRuntime.Spec.Containers[0].Resources.Limits).To(HaveKey(corev1.ResourceName("nvidia.com/gpu")))
+		Expect(injectAcceleratorProfile(servingRuntime, gpuProfile)).To(BeFalse())
+
+		gaudiProfile := &acceleratorProfile{Name: "intel-gaudi", Enabled: true, Identifier: "habana.ai/gaudi",
+			Tolerations: []corev1.Toleration{gaudiToleration}}
+		Expect(injectAcceleratorProfile(servingRuntime, gaudiProfile)).To(BeTrue())
+		Expect(servingRuntime.Spec.Tolerations).To(Equal([]corev1.Toleration{userToleration, gaudiToleration}))
+		Expect(servingRuntime.Spec.Containers[0].Resources.Limits).To(Equal(corev1.ResourceList{
+			"habana.ai/gaudi": resource.MustParse("1"),
+		}))
+		Expect(servingRuntime.Spec.Containers[0].Resources.Requests).To(Equal(corev1.ResourceList{
+			"habana.ai/gaudi": resource.MustParse("1"),
+		}))
+		Expect(servingRuntime.Annotations).To(HaveKeyWithValue(acceleratorNameAnnotation, "intel-gaudi"))
+
+		By("By checking that the whole injection is removed without a profile")
+
+		Expect(injectAcceleratorProfile(servingRuntime, nil)).To(BeTrue())
+		Expect(servingRuntime.Spec.Tolerations).To(Equal([]corev1.Toleration{userToleration}))
+		Expect(servingRuntime.Spec.Containers[0].Resources.Limits).To(BeEmpty())
+		Expect(servingRuntime.Spec.Containers[0].Resources.Requests).To(BeEmpty())
+		Expect(servingRuntime.Annotations).NotTo(HaveKey(acceleratorNameAnnotation))
+		Expect(servingRuntime.Annotations).NotTo(HaveKey(injectedAcceleratorAnnotation))
+	})
+
+	It("Should keep the resources set by the users", func() {
+		servingRuntime := &predictorv1.ServingRuntime{}
+		servingRuntime.Spec.Containers = []predictorv1.Container{{Name: "server"}}
+		servingRuntime.Spec.Containers[0].Resources.Limits = corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("2")}
+		profile := &acceleratorProfile{Name: "nvidia-gpu", Enabled: true, Identifier: "nvidia.com/gpu"}
+
+		Expect(injectAcceleratorProfile(servingRuntime, profile)).To(BeTrue())
+		Expect(injectAcceleratorProfile(servingRuntime, nil)).To(BeTrue())
+		Expect(servingRuntime.Spec.Containers[0].Resources.Limits).To(Equal(corev1.ResourceList{
+			"nvidia.com/gpu": resource.MustParse("2"),
+		}))
+	})
+
+	It("Should only keep the injected tolerations when rendering the Template", func() {
+		injectedToleration := corev1.Toleration{Key: "nvidia.com/gpu", Operator: corev1.TolerationOpExists}
+		servingRuntime := &predictorv1.ServingRuntime{}
+		servingRuntime.Spec.Containers = []predictorv1.Container{{Name: "server"}}
+		profile := &acceleratorProfile{Name: "nvidia-gpu", Enabled: true, Identifier: "nvidia.com/gpu",
+			Tolerations: []corev1.Toleration{injectedToleration}}
+		Expect(injectAcceleratorProfile(servingRuntime, profile)).To(BeTrue())
+		servingRuntime.Spec.Tolerations = append(servingRuntime.Spec.Tolerations,
+			corev1.Toleration{Key: "manual", Operator: corev1.TolerationOpExists})
+
+		desired := &predictorv1.ServingRuntimeSpec{}
+		desired.Containers = []predictorv1.Container{{Name: "server"}}
+		record := keepAcceleratorInjection(desired, servingRuntime)
+		Expect(desired.Tolerations).To(Equal([]corev1.Toleration{injectedToleration}))
+		Expect(desired.Containers[0].Resources.Limits).To(HaveKey(corev1.ResourceName("nvidia.com/gpu")))
+		Expect(record).To(Equal(servingRuntime.Annotations[injectedAcceleratorAnnotation]))
+	})
+})
@@ -0,0 +1,186 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	predictorv1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	ovmsDefaultRestPort = 8888
+)
+
+// containerArg returns the value passed to the container through the given flag,
+// supporting both the "--flag=value" and "--flag value" forms
+func containerArg(container predictorv1.Container, flag string) (string, bool) {
+	for i, arg := range container.Args {
+		if strings.HasPrefix(arg, flag+"=") {
+			return strings.TrimPrefix(arg, flag+"="), true
+		} else if arg == flag && i+1 < len(container.Args) {
+			return container.Args[i+1], true
+		}
+	}
+	return "", false
+}
+
+// containerArgPort returns the port passed to the container through the given flag
+func containerArgPort(container predictorv1.Container, flag string, defaultPort int32) int32 {
+	value, _ := containerArg(container, flag)
+	if port, err := strconv.ParseInt(value, 10, 32); err == nil {
+		return int32(port)
+	}
+	return defaultPort
+}
+
+// loopbackAddress returns true if the server listens on the loopback interface only
+func loopbackAddress(address string) bool {
+	return address == "127.0.0.1" || address == "localhost" || address == "::1"
+}
+
+// newRuntimeProbeHandler returns the health check handler matching the runtime
+// container image, or nil if the runtime is not recognized. The model servers bound to
+// the loopback interface, e.g. the OVMS runtime only reached by the ModelMesh adapter of
+// its pod, cannot be probed by the kubelet and get no probe
+func newRuntimeProbeHandler(container predictorv1.Container) *corev1.ProbeHandler {
+	image := container.Image
+	if strings.Contains(image, "openvino/model_server") || strings.Contains(image, "ovms") {
+		if address, ok := containerArg(container, "--rest_bind_address"); ok && loopbackAddress(address) {
+			return nil
+		}
+		return &corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path: "/v2/health/ready",
+				Port: intstr.FromInt(int(containerArgPort(container, "--rest_port", ovmsDefaultRestPort))),
+			},
+		}
+	}
+	return nil
+}
+
+// NewRuntimeReadinessProbe defines the readiness probe injected in a runtime container
+func NewRuntimeReadinessProbe(container predictorv1.Container) *corev1.Probe {
+	handler := newRuntimeProbeHandler(container)
+	if handler == nil {
+		return nil
+	}
+	return &corev1.Probe{
+		ProbeHandler:     *handler,
+		PeriodSeconds:    10,
+		TimeoutSeconds:   5,
+		FailureThreshold: 3,
+	}
+}
+
+// NewRuntimeLivenessProbe defines the liveness probe injected in a runtime container. The
+// initial delay gives large models time to load before the container can be restarted
+func NewRuntimeLivenessProbe(container predictorv1.Container) *corev1.Probe {
+	handler := newRuntimeProbeHandler(container)
+	if handler == nil {
+		return nil
+	}
+	return &corev1.Probe{
+		ProbeHandler:        *handler,
+		InitialDelaySeconds: 60,
+		PeriodSeconds:       30,
+		TimeoutSeconds:      5,
+		FailureThreshold:    5,
+	}
+}
+
+// injectServingRuntimeProbes sets the probes of the runtime containers that do not
+// define their own, returns true if the ServingRuntime was modified
+func injectServingRuntimeProbes(servingRuntime *predictorv1.ServingRuntime) bool {
+	changed := false
+	for i := range servingRuntime.Spec.Containers {
+		container := &servingRuntime.Spec.Containers[i]
+		if container.ReadinessProbe == nil {
+			if probe := NewRuntimeReadinessProbe(*container); probe != nil {
+				container.ReadinessProbe = probe
+				changed = true
+			}
+		}
+		if container.LivenessProbe == nil {
+			if probe := NewRuntimeLivenessProbe(*container); probe != nil {
+				container.LivenessProbe = probe
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// ReconcileServingRuntimeProbes will inject the readiness and liveness probes in the
+// containers of the serving runtime used by the InferenceService when the runtime has
+// the 'enable-probes' annotation set to 'true'
+func (r *OpenshiftInferenceServiceReconciler) ReconcileServingRuntimeProbes(
+	inferenceservice *inferenceservicev1.InferenceService, ctx context.Context) error {
+	// Initialize logger format
+	log := r.Log.WithValues("inferenceservice", inferenceservice.Name, "namespace", inferenceservice.Namespace)
+
+	runtimeName := explicitRuntimeName(inferenceservice)
+	if runtimeName == "" {
+		log.Info("Serving runtime selected by ModelMesh, leaving it unmodified")
+		return nil
+	}
+	runtimeKey := types.NamespacedName{
+		Name:      runtimeName,
+		Namespace: inferenceservice.Namespace,
+	}
+	servingRuntime := &predictorv1.ServingRuntime{}
+	err := r.Get(ctx, runtimeKey, servingRuntime)
+	if err != nil {
+		if apierrs.IsNotFound(err) {
+			return nil
+		}
+		log.Error(err, "Unable to fetch the Serving Runtime")
+		return err
+	}
+
+	if servingRuntime.Annotations["enable-probes"] != "true" {
+		return nil
+	}
+	if !injectServingRuntimeProbes(servingRuntime.DeepCopy()) {
+		return nil
+	}
+
+	log.Info("Injecting health probes in Serving Runtime " + servingRuntime.Name)
+	// Retry the update operation when the runtime is concurrently modified
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// Get the last serving runtime revision
+		if err := r.Get(ctx, runtimeKey, servingRuntime); err != nil {
+			return err
+		}
+		if !injectServingRuntimeProbes(servingRuntime) {
+			return nil
+		}
+		return r.Update(ctx, servingRuntime)
+	})
+	if err != nil {
+		log.Error(err, "Unable to inject health probes in the Serving Runtime")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	predictorv1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("The runtime health probes", func() {
+
+	DescribeTable("Should probe the REST port of the OVMS containers listening on the pod IP",
+		func(args []string, expectedPort int) {
+			container := predictorv1.Container{Name: "ovms", Image: "openvino/model_server:2022.2", Args: args}
+			handler := newRuntimeProbeHandler(container)
+			Expect(handler).NotTo(BeNil())
+			Expect(handler.HTTPGet.Path).To(Equal("/v2/health/ready"))
+			Expect(handler.HTTPGet.Port.IntValue()).To(Equal(expectedPort))
+		},
+		Entry("when the port is not set", []string{"--port=8001"}, ovmsDefaultRestPort),
+		Entry("when the port is set with an equal sign", []string{"--rest_port=8080"}, 8080),
+		Entry("when the port is set as the next argument", []string{"--rest_port", "8081"}, 8081),
+		Entry("when the server listens on all the interfaces", []string{"--rest_bind_address=0.0.0.0"},
+			ovmsDefaultRestPort),
+	)
+
+	DescribeTable("Should not probe the containers the kubelet cannot reach",
+		func(image string, args []string) {
+			container := predictorv1.Container{Name: "server", Image: image, Args: args}
+			Expect(newRuntimeProbeHandler(container)).To(BeNil())
+			servingRuntime := &predictorv1.ServingRuntime{}
+			servingRuntime.Spec.Containers = []predictorv1.Container{container}
+			Expect(injectServingRuntimeProbes(servingRuntime)).To(BeFalse())
+		},
+		Entry("when OVMS listens on localhost", "openvino/model_server:2022.2",
+			[]string{"--rest_port=8888", "--rest_bind_address=127.0.0.1"}),
+		Entry("when OVMS listens on localhost by name", "openvino/model_server:2022.2",
+			[]string{"--rest_bind_address", "localhost"}),
+		Entry("when the runtime is not recognized", "seldonio/mlserver:1.3.2", []string{}),
+	)
+
+	It("Should keep the probes defined by the runtime", func() {
+		probe := &corev1.Probe{ProbeHandler: corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: []string{"true"}}}}
+		servingRuntime := &predictorv1.ServingRuntime{}
+		servingRuntime.Spec.Containers = []predictorv1.Container{{
+			Name: "ovms", Image: "openvino/model_server:2022.2", ReadinessProbe: probe,
+		}}
+		Expect(injectServingRuntimeProbes(servingRuntime)).To(BeTrue())
+		Expect(servingRuntime.Spec.Containers[0].ReadinessProbe).To(Equal(probe))
+		Expect(servingRuntime.Spec.Containers[0].LivenessProbe).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,284 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/go-logr/logr"
+	predictorv1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlbuilder "sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// injectProxyAnnotation opts the ServingRuntime out of the proxy injection when "false"
+	injectProxyAnnotation = "serving.opendatahub.io/inject-proxy"
+	// injectedProxyEnvAnnotation records the proxy variables injected in the ServingRuntime
+	// as a JSON object of their values, so that they are updated when the cluster proxy
+	// changes while the variables set by the users are kept
+	injectedProxyEnvAnnotation = "serving.opendatahub.io/injected-proxy-env"
+)
+
+// proxyGVK is the cluster wide egress proxy configuration of Openshift, used through
+// unstructured objects to avoid depending on the config API module
+var proxyGVK = schema.GroupVersionKind{
+	Group:   "config.openshift.io",
+	Version: "v1",
+	Kind:    "Proxy",
+}
+
+// proxyEnvNames are the variables configuring the egress proxy, in the spec field order
+var proxyEnvNames = []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"}
+
+// newProxyEnv returns the variables having a value, in the order of proxyEnvNames
+func newProxyEnv(values map[string]string) []corev1.EnvVar {
+	env := []corev1.EnvVar{}
+	for _, name := range proxyEnvNames {
+		if values[name] != "" {
+			env = append(env, corev1.EnvVar{Name: name, Value: values[name]})
+		}
+	}
+	return env
+}
+
+// ReadProxyEnv returns the environment variables of the egress proxy of the cluster, from
+// the status of the cluster Proxy. It is empty when there is no cluster Proxy
+func ReadProxyEnv(ctx context.Context, reader client.Reader) ([]corev1.EnvVar, error) {
+	proxy := &unstructured.Unstructured{}
+	proxy.SetGroupVersionKind(proxyGVK)
+	err := reader.Get(ctx, types.NamespacedName{Name: "cluster"}, proxy)
+	if apierrs.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	// The status holds the effective configuration, e.g. the cluster networks are added to
+	// the noProxy of the spec
+	values := map[string]string{}
+	for name, field := range map[string]string{
+		"HTTP_PROXY":  "httpProxy",
+		"HTTPS_PROXY": "httpsProxy",
+		"NO_PROXY":    "noProxy",
+	} {
+		value, _, err := unstructured.NestedString(proxy.Object, "status", field)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = value
+	}
+	return newProxyEnv(values), nil
+}
+
+// ProxyEnvFromEnvironment returns the egress proxy variables of the controller environment,
+// e.g. injected by OLM or set in its Deployment on non-Openshift clusters
+func ProxyEnvFromEnvironment() []corev1.EnvVar {
+	values := map[string]string{}
+	for _, name := range proxyEnvNames {
+		values[name] = os.Getenv(name)
+	}
+	return newProxyEnv(values)
+}
+
+// injectProxyEnv sets the proxy variables in the runtime containers, returns true if the
+// ServingRuntime was modified. A variable is considered injected when it has the value
+// recorded in the injected-proxy-env annotation: it is then updated, or removed when no
+// longer part of the proxy configuration. The variables set by the users are kept
+func injectProxyEnv(servingRuntime *predictorv1.ServingRuntime, proxyEnv []corev1.EnvVar) bool {
+	injected := map[string]string{}
+	if value, ok := servingRuntime.Annotations[injectedProxyEnvAnnotation]; ok {
+		// An invalid record is ignored, the variables are then considered set by the users
+		_ = json.Unmarshal([]byte(value), &injected)
+	}
+	desired := map[string]string{}
+	for _, envVar := range proxyEnv {
+		desired[envVar.Name] = envVar.Value
+	}
+
+	changed := false
+	for i := range servingRuntime.Spec.Containers {
+		container := &servingRuntime.Spec.Containers[i]
+		env := []corev1.EnvVar{}
+		present := map[string]bool{}
+		containerChanged := false
+		for _, existing := range container.Env {
+			value, wasInjected := injected[existing.Name]
+			if wasInjected && existing.ValueFrom == nil && existing.Value == value {
+				desiredValue, ok := desired[existing.Name]
+				if !ok {
+					containerChanged = true
+					continue
+				}
+				if desiredValue != existing.Value {
+					existing.Value = desiredValue
+					containerChanged = true
+				}
+			}
+			env = append(env, existing)
+			present[existing.Name] = true
+		}
+		for _, envVar := range proxyEnv {
+			if !present[envVar.Name] {
+				env = append(env, envVar)
+				containerChanged = true
+			}
+		}
+		if containerChanged {
+			container.Env = env
+			changed = true
+		}
+	}
+
+	if len(desired) == 0 {
+		if _, ok := servingRuntime.Annotations[injectedProxyEnvAnnotation]; ok {
+			delete(servingRuntime.Annotations, injectedProxyEnvAnnotation)
+			changed = true
+		}
+		return changed
+	}
+	// The keys of the maps are sorted, the record is stable
+	record, _ := json.Marshal(desired)
+	if servingRuntime.Annotations[injectedProxyEnvAnnotation] != string(record) {
+		if servingRuntime.Annotations == nil {
+			servingRuntime.Annotations = map[string]string{}
+		}
+		servingRuntime.Annotations[injectedProxyEnvAnnotation] = string(record)
+		changed = true
+	}
+	return changed
+}
+
+// ReconcileServingRuntimeProxy will inject the egress proxy variables of the cluster in the
+// containers of the serving runtime used by the InferenceService, so that the model servers
+// reaching external services go through the proxy. The models are downloaded by the puller
+// of the adapter container injected by ModelMesh, which is not modified. The variables set
+// by the runtime are kept, and the runtime opts out with the
+// 'serving.opendatahub.io/inject-proxy' annotation set to 'false', the injected variables
+// are then removed
+func (r *OpenshiftInferenceServiceReconciler) ReconcileServingRuntimeProxy(
+	inferenceservice *inferenceservicev1.InferenceService, ctx context.Context) error {
+	// Initialize logger format
+	log := r.Log.WithValues("inferenceservice", inferenceservice.Name, "namespace", inferenceservice.Namespace)
+
+	runtimeName := explicitRuntimeName(inferenceservice)
+	if runtimeName == "" {
+		log.Info("Serving runtime selected by ModelMesh, leaving it unmodified")
+		return nil
+	}
+	runtimeKey := types.NamespacedName{
+		Name:      runtimeName,
+		Namespace: inferenceservice.Namespace,
+	}
+	servingRuntime := &predictorv1.ServingRuntime{}
+	err := r.Get(ctx, runtimeKey, servingRuntime)
+	if err != nil {
+		if apierrs.IsNotFound(err) {
+			return nil
+		}
+		log.Error(err, "Unable to fetch the Serving Runtime")
+		return err
+	}
+
+	if r.pausedByUsers(servingRuntime, inferenceservice, log) {
+		return nil
+	}
+	proxyEnv := r.ProxyEnv
+	if servingRuntime.Annotations[injectProxyAnnotation] == "false" {
+		proxyEnv = nil
+	}
+	if !injectProxyEnv(servingRuntime.DeepCopy(), proxyEnv) {
+		return nil
+	}
+
+	log.Info("Injecting the proxy configuration in Serving Runtime " + servingRuntime.Name)
+	// Retry the update operation when the runtime is concurrently modified
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// Get the last serving runtime revision
+		if err := r.Get(ctx, runtimeKey, servingRuntime); err != nil {
+			return err
+		}
+		if !injectProxyEnv(servingRuntime, proxyEnv) {
+			return nil
+		}
+		return r.Update(ctx, servingRuntime)
+	})
+	if err != nil {
+		log.Error(err, "Unable to inject the proxy configuration in the Serving Runtime")
+		return err
+	}
+	auditLog(AuditActionUpdate, "ServingRuntime", servingRuntime, "Proxy configuration injected")
+	return nil
+}
+
+// ProxyConfigReconciler watches the cluster Proxy and stops the manager when its variables
+// differ from the ones the controllers were set up with, so that they are set up again and
+// the ServingRuntimes get the new proxy configuration
+type ProxyConfigReconciler struct {
+	client.Client
+	Log logr.Logger
+	// Env is the proxy configuration read from the cluster Proxy at startup
+	Env []corev1.EnvVar
+	// Stop stops the manager running the controllers
+	Stop    context.CancelFunc
+	changed int32
+}
+
+// Reconcile compares the variables of the cluster Proxy with the current ones
+func (r *ProxyConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	env, err := ReadProxyEnv(ctx, r.Client)
+	if err != nil {
+		r.Log.Error(err, "Unable to read the cluster Proxy")
+		return ctrl.Result{}, err
+	}
+	if len(env) == len(r.Env) && (len(env) == 0 || reflect.DeepEqual(env, r.Env)) {
+		return ctrl.Result{}, nil
+	}
+	r.Log.Info("Cluster proxy changed, setting up the controllers again", "Proxy", req.Name)
+	atomic.StoreInt32(&r.changed, 1)
+	r.Stop()
+	return ctrl.Result{}, nil
+}
+
+// Changed returns true if the manager was stopped because the cluster proxy changed
+func (r *ProxyConfigReconciler) Changed() bool {
+	return atomic.LoadInt32(&r.changed) == 1
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ProxyConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	proxy := &unstructured.Unstructured{}
+	proxy.SetGroupVersionKind(proxyGVK)
+	clusterProxy := predicate.NewPredicateFuncs(func(o client.Object) bool {
+		return o.GetName() == "cluster"
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("proxyconfig").
+		For(proxy, ctrlbuilder.WithPredicates(clusterProxy)).
+		Complete(r)
+}
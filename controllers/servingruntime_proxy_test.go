@@ -0,0 +1,92 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	predictorv1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// newProxyRuntime returns a ServingRuntime with a single container defining the variables
+func newProxyRuntime(env ...corev1.EnvVar) *predictorv1.ServingRuntime {
+	servingRuntime := &predictorv1.ServingRuntime{}
+	servingRuntime.Name = "proxy-runtime"
+	servingRuntime.Spec.Containers = []predictorv1.Container{{Name: "server", Env: env}}
+	return servingRuntime
+}
+
+var _ = Describe("The proxy injection", func() {
+	proxyEnv := []corev1.EnvVar{
+		{Name: "HTTP_PROXY", Value: "http://proxy:3128"},
+		{Name: "NO_PROXY", Value: ".cluster.local"},
+	}
+
+	It("Should inject the proxy variables and keep the ones set by the users", func() {
+		servingRuntime := newProxyRuntime(corev1.EnvVar{Name: "NO_PROXY", Value: "user.example.com"})
+
+		Expect(injectProxyEnv(servingRuntime, proxyEnv)).To(BeTrue())
+		Expect(servingRuntime.Spec.Containers[0].Env).To(Equal([]corev1.EnvVar{
+			{Name: "NO_PROXY", Value: "user.example.com"},
+			{Name: "HTTP_PROXY", Value: "http://proxy:3128"},
+		}))
+		Expect(servingRuntime.Annotations[injectedProxyEnvAnnotation]).
+			To(Equal(`{"HTTP_PROXY":"http://proxy:3128","NO_PROXY":".cluster.local"}`))
+
+		Expect(injectProxyEnv(servingRuntime, proxyEnv)).To(BeFalse())
+	})
+
+	It("Should update the injected variables when the proxy changes", func() {
+		servingRuntime := newProxyRuntime(corev1.EnvVar{Name: "LOG_LEVEL", Value: "debug"})
+		Expect(injectProxyEnv(servingRuntime, proxyEnv)).To(BeTrue())
+
+		changedProxyEnv := []corev1.EnvVar{
+			{Name: "HTTPS_PROXY", Value: "http://proxy:3129"},
+			{Name: "NO_PROXY", Value: ".cluster.local,.svc"},
+		}
+		Expect(injectProxyEnv(servingRuntime, changedProxyEnv)).To(BeTrue())
+		Expect(servingRuntime.Spec.Containers[0].Env).To(Equal([]corev1.EnvVar{
+			{Name: "LOG_LEVEL", Value: "debug"},
+			{Name: "NO_PROXY", Value: ".cluster.local,.svc"},
+			{Name: "HTTPS_PROXY", Value: "http://proxy:3129"},
+		}))
+		Expect(injectProxyEnv(servingRuntime, changedProxyEnv)).To(BeFalse())
+	})
+
+	It("Should keep the injected variables modified by the users", func() {
+		servingRuntime := newProxyRuntime()
+		Expect(injectProxyEnv(servingRuntime, proxyEnv)).To(BeTrue())
+		servingRuntime.Spec.Containers[0].Env[0].Value = "http://user-proxy:3128"
+
+		Expect(injectProxyEnv(servingRuntime, nil)).To(BeTrue())
+		Expect(servingRuntime.Spec.Containers[0].Env).To(Equal([]corev1.EnvVar{
+			{Name: "HTTP_PROXY", Value: "http://user-proxy:3128"},
+		}))
+		Expect(servingRuntime.Annotations).NotTo(HaveKey(injectedProxyEnvAnnotation))
+	})
+
+	It("Should keep removing the injected variables once the cluster proxy is removed", func() {
+		reconciler := &OpenshiftInferenceServiceReconciler{InjectProxy: true}
+		enabled := false
+		for _, subReconciler := range reconciler.subReconcilers() {
+			if subReconciler.name == "proxy" {
+				enabled = subReconciler.enabled
+			}
+		}
+		Expect(enabled).To(BeTrue())
+	})
+})
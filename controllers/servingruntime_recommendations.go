@@ -0,0 +1,280 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	predictorv1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// recommendedCPUAnnotation and recommendedMemoryAnnotation suggest the requests of the
+	// model server container of the ServingRuntime from its observed usage
+	recommendedCPUAnnotation    = "serving.opendatahub.io/recommended-cpu-request"
+	recommendedMemoryAnnotation = "serving.opendatahub.io/recommended-memory-request"
+	// recommendedGPUMemoryAnnotation suggests the GPU memory the model server needs, e.g. to
+	// choose the GPU model or MIG profile, as the GPUs are requested as whole devices
+	recommendedGPUMemoryAnnotation = "serving.opendatahub.io/recommended-gpu-memory"
+	// recommendationHeadroom is the margin added to the observed usage
+	recommendationHeadroom = 1.2
+	// prometheusQueryTimeout bounds the duration of each query to Prometheus
+	prometheusQueryTimeout = 30 * time.Second
+)
+
+// ResourceRecommender periodically queries Prometheus for the CPU, memory and GPU memory
+// usage of the model server containers of the ServingRuntimes, and annotates them with the
+// requests fitting it. The usage is observed per runtime, as the models of ModelMesh share its pods
+type ResourceRecommender struct {
+	client.Client
+	Log logr.Logger
+	// PrometheusURL is the URL of the Prometheus, or Thanos Querier, API
+	PrometheusURL string
+	// BearerTokenFile is the token authenticating the queries, none when empty
+	BearerTokenFile string
+	// CAFile is the CA bundle verifying the Prometheus certificate, the system one when empty
+	CAFile string
+	// Interval between the analyses, and Window of the observed usage
+	Interval time.Duration
+	Window   time.Duration
+
+	httpClient *http.Client
+}
+
+// prometheusResponse holds the vector result of an instant query
+type prometheusResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Value []interface{} `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// query runs the instant query and returns its scalar result, false when it has none
+func (r *ResourceRecommender) query(ctx context.Context, query string) (float64, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, prometheusQueryTimeout)
+	defer cancel()
+	endpoint := strings.TrimRight(r.PrometheusURL, "/") + "/api/v1/query?" + url.Values{"query": {query}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	if r.BearerTokenFile != "" {
+		// The token is read for every query, as the projected service account tokens rotate
+		token, err := os.ReadFile(r.BearerTokenFile)
+		if err != nil {
+			return 0, false, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return 0, false, err
+	}
+	defer resp.Body.Close()
+
+	response := &prometheusResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return 0, false, fmt.Errorf("unexpected response from Prometheus: %s", resp.Status)
+	}
+	if response.Status != "success" {
+		return 0, false, fmt.Errorf("query failed: %s", response.Error)
+	}
+	if response.Data.ResultType != "vector" || len(response.Data.Result) == 0 ||
+		len(response.Data.Result[0].Value) != 2 {
+		return 0, false, nil
+	}
+	value, ok := response.Data.Result[0].Value[1].(string)
+	if !ok {
+		return 0, false, fmt.Errorf("unexpected sample value %v", response.Data.Result[0].Value[1])
+	}
+	sample, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(sample) || math.IsInf(sample, 0) {
+		return 0, false, nil
+	}
+	return sample, true, nil
+}
+
+// runtimeUsageQueries returns the queries of the peak CPU, in cores, memory, in bytes, and
+// GPU memory, in mebibytes, used by the model server container of the ServingRuntime over
+// the window. The GPU memory comes from the NVIDIA DCGM exporter, which labels the metrics
+// with the pod using the GPU as exported_pod
+func runtimeUsageQueries(servingRuntime *predictorv1.ServingRuntime, window time.Duration) (string, string, string) {
+	pods := modelmeshServiceName + "-" + servingRuntime.Name + "-.*"
+	container := servingRuntime.Spec.Containers[0].Name
+	selector := fmt.Sprintf(`namespace=%q,pod=~%q,container=%q`, servingRuntime.Namespace, pods, container)
+	gpuSelector := fmt.Sprintf(`exported_namespace=%q,exported_pod=~%q,exported_container=%q`,
+		servingRuntime.Namespace, pods, container)
+	rangeWindow := fmt.Sprintf("%ds", int64(window.Seconds()))
+	cpuQuery := fmt.Sprintf(`max(quantile_over_time(0.95, rate(container_cpu_usage_seconds_total{%s}[5m])[%s:5m]))`,
+		selector, rangeWindow)
+	memoryQuery := fmt.Sprintf(`max(max_over_time(container_memory_working_set_bytes{%s}[%s]))`,
+		selector, rangeWindow)
+	// The memory of all the GPUs of a pod is summed
+	gpuMemoryQuery := fmt.Sprintf(`max(max_over_time(sum by (exported_pod) (DCGM_FI_DEV_FB_USED{%s})[%s:5m]))`,
+		gpuSelector, rangeWindow)
+	return cpuQuery, memoryQuery, gpuMemoryQuery
+}
+
+// recommendedQuantities returns the requests fitting the observed usage with the headroom,
+// rounded up to the millicore and the mebibyte
+func recommendedQuantities(cpuCores float64, memoryBytes float64) (string, string) {
+	millicores := int64(math.Ceil(cpuCores * recommendationHeadroom * 1000))
+	if millicores < 1 {
+		millicores = 1
+	}
+	mebibytes := int64(math.Ceil(memoryBytes * recommendationHeadroom / (1 << 20)))
+	if mebibytes < 1 {
+		mebibytes = 1
+	}
+	return resource.NewMilliQuantity(millicores, resource.DecimalSI).String(),
+		resource.NewQuantity(mebibytes<<20, resource.BinarySI).String()
+}
+
+// recommendedGPUMemory returns the GPU memory fitting the observed usage with the headroom,
+// rounded up to the mebibyte
+func recommendedGPUMemory(gpuMemoryMebibytes float64) string {
+	mebibytes := int64(math.Ceil(gpuMemoryMebibytes * recommendationHeadroom))
+	if mebibytes < 1 {
+		mebibytes = 1
+	}
+	return resource.NewQuantity(mebibytes<<20, resource.BinarySI).String()
+}
+
+// recommend annotates the ServingRuntime with the requests fitting its observed usage
+func (r *ResourceRecommender) recommend(ctx context.Context, servingRuntime *predictorv1.ServingRuntime) error {
+	if len(servingRuntime.Spec.Containers) == 0 || managementPaused(servingRuntime, r.Log) {
+		return nil
+	}
+	cpuQuery, memoryQuery, gpuMemoryQuery := runtimeUsageQueries(servingRuntime, r.Window)
+	cpuCores, cpuFound, err := r.query(ctx, cpuQuery)
+	if err != nil {
+		return err
+	}
+	memoryBytes, memoryFound, err := r.query(ctx, memoryQuery)
+	if err != nil {
+		return err
+	}
+	// The runtime has not been running during the window
+	if !cpuFound || !memoryFound {
+		return nil
+	}
+	cpu, memory := recommendedQuantities(cpuCores, memoryBytes)
+	// There is no GPU memory usage when the runtime does not use GPUs, or the DCGM exporter
+	// is not installed
+	gpuMemoryMebibytes, gpuFound, err := r.query(ctx, gpuMemoryQuery)
+	if err != nil {
+		return err
+	}
+	gpuMemory := ""
+	if gpuFound {
+		gpuMemory = recommendedGPUMemory(gpuMemoryMebibytes)
+	}
+	if servingRuntime.Annotations[recommendedCPUAnnotation] == cpu &&
+		servingRuntime.Annotations[recommendedMemoryAnnotation] == memory &&
+		servingRuntime.Annotations[recommendedGPUMemoryAnnotation] == gpuMemory {
+		return nil
+	}
+
+	key := types.NamespacedName{Name: servingRuntime.Name, Namespace: servingRuntime.Namespace}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// Get the last serving runtime revision
+		if err := r.Get(ctx, key, servingRuntime); err != nil {
+			return err
+		}
+		if servingRuntime.Annotations == nil {
+			servingRuntime.Annotations = map[string]string{}
+		}
+		servingRuntime.Annotations[recommendedCPUAnnotation] = cpu
+		servingRuntime.Annotations[recommendedMemoryAnnotation] = memory
+		if gpuMemory == "" {
+			delete(servingRuntime.Annotations, recommendedGPUMemoryAnnotation)
+		} else {
+			servingRuntime.Annotations[recommendedGPUMemoryAnnotation] = gpuMemory
+		}
+		return r.Update(ctx, servingRuntime)
+	})
+}
+
+// analyze annotates all the ServingRuntimes with their recommendations
+func (r *ResourceRecommender) analyze(ctx context.Context) {
+	servingRuntimes := &predictorv1.ServingRuntimeList{}
+	if err := r.List(ctx, servingRuntimes); err != nil {
+		r.Log.Error(err, "Unable to list the Serving Runtimes")
+		return
+	}
+	for i := range servingRuntimes.Items {
+		servingRuntime := &servingRuntimes.Items[i]
+		if err := r.recommend(ctx, servingRuntime); err != nil {
+			r.Log.Error(err, "Unable to recommend the resources of the Serving Runtime",
+				"ServingRuntime", servingRuntime.Name, "namespace", servingRuntime.Namespace)
+		}
+	}
+}
+
+// Start analyzes the usage of the ServingRuntimes every Interval until the context is done
+func (r *ResourceRecommender) Start(ctx context.Context) error {
+	tlsConfig := &tls.Config{}
+	if r.CAFile != "" {
+		caBundle, err := os.ReadFile(r.CAFile)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBundle) {
+			return fmt.Errorf("invalid CA bundle %s", r.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	r.httpClient = &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig},
+	}
+
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	for {
+		r.analyze(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection returns true, only the leader annotates the ServingRuntimes
+func (r *ResourceRecommender) NeedLeaderElection() bool {
+	return true
+}
@@ -0,0 +1,97 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+// prometheusResponses are the bodies returned by the fake Prometheus API for each query
+var prometheusResponses = map[string]string{
+	"sample":  `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000.1,"1.5"]}]}}`,
+	"empty":   `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+	"nan":     `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000.1,"NaN"]}]}}`,
+	"failure": `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+	"invalid": `<html>Service Unavailable</html>`,
+}
+
+var _ = Describe("The resource recommendations", func() {
+
+	DescribeTable("Should add the headroom to the observed usage",
+		func(cpuCores float64, memoryBytes float64, expectedCPU string, expectedMemory string) {
+			cpu, memory := recommendedQuantities(cpuCores, memoryBytes)
+			Expect(cpu).To(Equal(expectedCPU))
+			Expect(memory).To(Equal(expectedMemory))
+		},
+		Entry("when the runtime uses cores and gibibytes", 2.0, float64(1<<30), "2400m", "1229Mi"),
+		Entry("when the runtime uses a fraction of a core", 0.25, float64(300<<20), "300m", "360Mi"),
+		Entry("when the runtime is idle", 0.0, 0.0, "1m", "1Mi"),
+	)
+
+	DescribeTable("Should add the headroom to the observed GPU memory usage",
+		func(gpuMemoryMebibytes float64, expected string) {
+			Expect(recommendedGPUMemory(gpuMemoryMebibytes)).To(Equal(expected))
+		},
+		Entry("when the usage is in mebibytes", 10000.0, "12000Mi"),
+		Entry("when the usage is in gibibytes", 15360.0, "18Gi"),
+		Entry("when the GPU memory is barely used", 0.1, "1Mi"),
+	)
+
+	Context("When querying Prometheus", func() {
+		var server *httptest.Server
+		var recommender *ResourceRecommender
+
+		BeforeEach(func() {
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				fmt.Fprint(w, prometheusResponses[req.URL.Query().Get("query")])
+			}))
+			recommender = &ResourceRecommender{PrometheusURL: server.URL + "/", httpClient: server.Client()}
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		DescribeTable("Should parse the vector result",
+			func(query string, expected float64, expectedFound bool) {
+				sample, found, err := recommender.query(context.Background(), query)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found).To(Equal(expectedFound))
+				Expect(sample).To(Equal(expected))
+			},
+			Entry("when there is a sample", "sample", 1.5, true),
+			Entry("when there is no sample", "empty", 0.0, false),
+			Entry("when the sample is not a number", "nan", 0.0, false),
+		)
+
+		DescribeTable("Should fail",
+			func(query string) {
+				_, found, err := recommender.query(context.Background(), query)
+				Expect(err).To(HaveOccurred())
+				Expect(found).To(BeFalse())
+			},
+			Entry("when the query fails", "failure"),
+			Entry("when the response is not from the Prometheus API", "invalid"),
+		)
+	})
+})
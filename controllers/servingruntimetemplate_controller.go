@@ -0,0 +1,298 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-logr/logr"
+	predictorv1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
+	templatev1 "github.com/openshift/api/template/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// templateNameAnnotation references the Template the ServingRuntime was created from,
+	// it is set by the ODH dashboard
+	templateNameAnnotation = "opendatahub.io/template-name"
+	// templateSyncAnnotation opts the ServingRuntime in the synchronization with its
+	// Template, so that the runtime upgrades are rolled out from the Template
+	templateSyncAnnotation = "serving.opendatahub.io/template-sync"
+	// templateParametersAnnotation overrides the values of the parameters of the Template
+	// with a comma separated list of NAME=value
+	templateParametersAnnotation = "serving.opendatahub.io/template-parameters"
+	// servingRuntimeTemplateField indexes the ServingRuntimes synchronized with a Template
+	// by the name of the Template
+	servingRuntimeTemplateField = "metadata.annotations.template-name"
+)
+
+// ServingRuntimeTemplateReconciler keeps the ServingRuntimes created from the ServingRuntime
+// Templates of the ODH dashboard in sync with them
+type ServingRuntimeTemplateReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Log    logr.Logger
+	// TemplatesNS is the namespace of the ServingRuntime Templates
+	TemplatesNS string
+	// ProxyEnv are the egress proxy variables injected in the ServingRuntimes by the
+	// InferenceService controller, see OpenshiftInferenceServiceReconciler
+	ProxyEnv []corev1.EnvVar
+}
+
+// +kubebuilder:rbac:groups=template.openshift.io,resources=templates,verbs=get;list;watch
+
+// templateSyncEnabled returns true if the ServingRuntime is synchronized with its Template
+func templateSyncEnabled(servingRuntime client.Object) bool {
+	return servingRuntime.GetAnnotations()[templateNameAnnotation] != "" &&
+		servingRuntime.GetAnnotations()[templateSyncAnnotation] == "true"
+}
+
+// templateParameters returns the values of the parameters of the Template, overridden by the
+// annotation of the ServingRuntime
+func templateParameters(template *templatev1.Template, servingRuntime *predictorv1.ServingRuntime) (map[string]string, error) {
+	overrides := map[string]string{}
+	for _, item := range strings.Split(servingRuntime.Annotations[templateParametersAnnotation], ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid %s annotation, expected NAME=value, got %q", templateParametersAnnotation, item)
+		}
+		overrides[parts[0]] = parts[1]
+	}
+
+	values := map[string]string{}
+	for _, parameter := range template.Parameters {
+		value, ok := overrides[parameter.Name]
+		if !ok {
+			value = parameter.Value
+		}
+		if value == "" && parameter.Generate != "" {
+			return nil, fmt.Errorf("the generated parameter %s is not supported, set its value", parameter.Name)
+		}
+		if value == "" && parameter.Required {
+			return nil, fmt.Errorf("the required parameter %s has no value", parameter.Name)
+		}
+		values[parameter.Name] = value
+	}
+	return values, nil
+}
+
+// substituteTemplateParameters replaces the parameter references of the JSON object, as
+// done by the Template processing: ${NAME} is replaced in the strings while ${{NAME}} is
+// replaced by the raw value, e.g. a number
+func substituteTemplateParameters(raw string, values map[string]string) (string, error) {
+	for name, value := range values {
+		escaped, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		raw = strings.ReplaceAll(raw, `"${{`+name+`}}"`, value)
+		raw = strings.ReplaceAll(raw, "${{"+name+"}}", value)
+		raw = strings.ReplaceAll(raw, "${"+name+"}", string(escaped[1:len(escaped)-1]))
+	}
+	return raw, nil
+}
+
+// renderServingRuntimeTemplate returns the spec of the ServingRuntime of the Template with
+// the parameters substituted
+func renderServingRuntimeTemplate(template *templatev1.Template, values map[string]string) (*predictorv1.ServingRuntimeSpec, error) {
+	for _, object := range template.Objects {
+		typeMeta := metav1.TypeMeta{}
+		if err := json.Unmarshal(object.Raw, &typeMeta); err != nil {
+			return nil, err
+		}
+		if typeMeta.Kind != "ServingRuntime" {
+			continue
+		}
+		raw, err := substituteTemplateParameters(string(object.Raw), values)
+		if err != nil {
+			return nil, err
+		}
+		servingRuntime := &predictorv1.ServingRuntime{}
+		if err := json.Unmarshal([]byte(raw), servingRuntime); err != nil {
+			return nil, fmt.Errorf("invalid ServingRuntime in the template: %w", err)
+		}
+		return &servingRuntime.Spec, nil
+	}
+	return nil, fmt.Errorf("the template has no ServingRuntime")
+}
+
+// keepAcceleratorInjection applies to the rendered spec the tolerations and the accelerators
+// recorded as injected in the ServingRuntime from its AcceleratorProfile, which the Template
+// does not know about. The other tolerations and resources of the runtime are reverted. It
+// returns the record of the injection, without the entries now set by the Template
+func keepAcceleratorInjection(desired *predictorv1.ServingRuntimeSpec, servingRuntime *predictorv1.ServingRuntime) string {
+	rendered := &predictorv1.ServingRuntime{Spec: *desired}
+	applyAcceleratorInjection(rendered, acceleratorInjection{}, injectedAccelerator(servingRuntime))
+	*desired = rendered.Spec
+	return rendered.Annotations[injectedAcceleratorAnnotation]
+}
+
+// keepRuntimeInjections applies to the rendered spec the probes and the proxy variables the
+// InferenceService controller injects in the ServingRuntime, otherwise both controllers would
+// keep reverting the changes of each other. It returns the record of the injected proxy
+// variables, empty when there are none
+func (r *ServingRuntimeTemplateReconciler) keepRuntimeInjections(desired *predictorv1.ServingRuntimeSpec,
+	servingRuntime *predictorv1.ServingRuntime) string {
+	rendered := &predictorv1.ServingRuntime{
+		ObjectMeta: *servingRuntime.ObjectMeta.DeepCopy(),
+		Spec:       *desired,
+	}
+	if servingRuntime.Annotations["enable-probes"] == "true" {
+		injectServingRuntimeProbes(rendered)
+	}
+	proxyEnv := r.ProxyEnv
+	if servingRuntime.Annotations[injectProxyAnnotation] == "false" {
+		proxyEnv = nil
+	}
+	injectProxyEnv(rendered, proxyEnv)
+	*desired = rendered.Spec
+	return rendered.Annotations[injectedProxyEnvAnnotation]
+}
+
+// Reconcile renders the Template of the ServingRuntime and updates the ServingRuntime
+// when its spec differs
+func (r *ServingRuntimeTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// Initialize logger format
+	log := r.Log.WithValues("ServingRuntime", req.Name, "namespace", req.Namespace)
+
+	servingRuntime := &predictorv1.ServingRuntime{}
+	err := r.Get(ctx, req.NamespacedName, servingRuntime)
+	if apierrs.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the Serving Runtime")
+		return ctrl.Result{}, err
+	}
+	if !templateSyncEnabled(servingRuntime) || managementPaused(servingRuntime, log) {
+		return ctrl.Result{}, nil
+	}
+
+	templateName := servingRuntime.Annotations[templateNameAnnotation]
+	template := &templatev1.Template{}
+	err = r.Get(ctx, types.NamespacedName{Name: templateName, Namespace: r.TemplatesNS}, template)
+	if apierrs.IsNotFound(err) {
+		log.Info("Template " + templateName + " was not found")
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		log.Error(err, "Unable to fetch the Template "+templateName)
+		return ctrl.Result{}, err
+	}
+
+	// Retrying will not fix the Template nor the parameters, they are reported in the logs
+	values, err := templateParameters(template, servingRuntime)
+	if err != nil {
+		log.Error(err, "Unable to get the parameters of the Template "+templateName)
+		return ctrl.Result{}, nil
+	}
+	desiredSpec, err := renderServingRuntimeTemplate(template, values)
+	if err != nil {
+		log.Error(err, "Unable to render the Template "+templateName)
+		return ctrl.Result{}, nil
+	}
+	injectedAcceleratorRecord := keepAcceleratorInjection(desiredSpec, servingRuntime)
+	injectedProxyEnv := r.keepRuntimeInjections(desiredSpec, servingRuntime)
+	if reflect.DeepEqual(*desiredSpec, servingRuntime.Spec) &&
+		injectedAcceleratorRecord == servingRuntime.Annotations[injectedAcceleratorAnnotation] &&
+		injectedProxyEnv == servingRuntime.Annotations[injectedProxyEnvAnnotation] {
+		return ctrl.Result{}, nil
+	}
+
+	log.Info("Synchronizing the Serving Runtime with the Template " + templateName)
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		// Get the last serving runtime revision
+		if err := r.Get(ctx, req.NamespacedName, servingRuntime); err != nil {
+			return err
+		}
+		servingRuntime.Spec = *desiredSpec
+		if injectedAcceleratorRecord == "" {
+			delete(servingRuntime.Annotations, injectedAcceleratorAnnotation)
+		} else {
+			servingRuntime.Annotations[injectedAcceleratorAnnotation] = injectedAcceleratorRecord
+		}
+		if injectedProxyEnv == "" {
+			delete(servingRuntime.Annotations, injectedProxyEnvAnnotation)
+		} else {
+			servingRuntime.Annotations[injectedProxyEnvAnnotation] = injectedProxyEnv
+		}
+		return r.Update(ctx, servingRuntime)
+	})
+	if err != nil {
+		log.Error(err, "Unable to synchronize the Serving Runtime with its Template")
+		return ctrl.Result{}, err
+	}
+	auditLog(AuditActionUpdate, "ServingRuntime", servingRuntime, "ServingRuntime synchronized with the Template "+templateName)
+	return ctrl.Result{}, nil
+}
+
+// requeueTemplateServingRuntimes returns the reconcile requests of the ServingRuntimes
+// synchronized with the modified Template
+func (r *ServingRuntimeTemplateReconciler) requeueTemplateServingRuntimes(o client.Object) []reconcile.Request {
+	if o.GetNamespace() != r.TemplatesNS {
+		return []reconcile.Request{}
+	}
+	servingRuntimes := &predictorv1.ServingRuntimeList{}
+	err := r.List(context.TODO(), servingRuntimes, client.MatchingFields{servingRuntimeTemplateField: o.GetName()})
+	if err != nil {
+		r.Log.Info("Error getting list of serving runtimes for template " + o.GetName())
+		return []reconcile.Request{}
+	}
+	reconcileRequests := []reconcile.Request{}
+	for _, servingRuntime := range servingRuntimes.Items {
+		reconcileRequests = append(reconcileRequests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: servingRuntime.Name, Namespace: servingRuntime.Namespace},
+		})
+	}
+	return reconcileRequests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ServingRuntimeTemplateReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &predictorv1.ServingRuntime{},
+		servingRuntimeTemplateField, func(o client.Object) []string {
+			if !templateSyncEnabled(o) {
+				return nil
+			}
+			return []string{o.GetAnnotations()[templateNameAnnotation]}
+		})
+	if err != nil {
+		return err
+	}
+
+	// The ServingRuntimes are also reconciled by the MonitoringReconciler
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("servingruntimetemplate").
+		For(&predictorv1.ServingRuntime{}).
+		Watches(&source.Kind{Type: &templatev1.Template{}},
+			handler.EnqueueRequestsFromMapFunc(r.requeueTemplateServingRuntimes)).
+		Complete(r)
+}
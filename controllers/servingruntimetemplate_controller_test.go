@@ -0,0 +1,92 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	mmv1alpha1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
+	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
+	mfc "github.com/manifestival/controller-runtime-client"
+	mf "github.com/manifestival/manifestival"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	templatev1 "github.com/openshift/api/template/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("The ServingRuntime Template controller", func() {
+
+	Context("When a ServingRuntime synchronized with its Template has 'enable-probes' enabled", func() {
+
+		It("Should keep the injected probes without updating the runtime again", func() {
+			client := mfc.NewClient(cli)
+			opts := mf.UseClient(client)
+			ctx := context.Background()
+
+			servingRuntime := &mmv1alpha1.ServingRuntime{}
+			err := convertToStructuredResource(ServingRuntimePath1, servingRuntime, opts)
+			Expect(err).NotTo(HaveOccurred())
+
+			templateRuntime := &mmv1alpha1.ServingRuntime{
+				TypeMeta: metav1.TypeMeta{APIVersion: "serving.kserve.io/v1alpha1", Kind: "ServingRuntime"},
+				Spec:     servingRuntime.Spec,
+			}
+			templateRuntime.Name = servingRuntime.Name
+			raw, err := json.Marshal(templateRuntime)
+			Expect(err).NotTo(HaveOccurred())
+			template := &templatev1.Template{
+				ObjectMeta: metav1.ObjectMeta{Name: "ovms-template", Namespace: WorkingNamespace},
+				Objects:    []runtime.RawExtension{{Raw: raw}},
+			}
+			Expect(cli.Create(ctx, template)).Should(Succeed())
+
+			servingRuntime.Annotations["enable-probes"] = "true"
+			servingRuntime.Annotations[templateNameAnnotation] = template.Name
+			servingRuntime.Annotations[templateSyncAnnotation] = "true"
+			Expect(cli.Create(ctx, servingRuntime)).Should(Succeed())
+
+			inferenceService := &inferenceservicev1.InferenceService{}
+			err = convertToStructuredResource(InferenceService1, inferenceService, opts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cli.Create(ctx, inferenceService)).Should(Succeed())
+
+			By("By checking that the probes are injected")
+
+			key := types.NamespacedName{Name: servingRuntime.Name, Namespace: servingRuntime.Namespace}
+			Eventually(func() bool {
+				if err := cli.Get(ctx, key, servingRuntime); err != nil {
+					return false
+				}
+				return servingRuntime.Spec.Containers[0].ReadinessProbe != nil
+			}, timeout, interval).Should(BeTrue())
+
+			By("By checking that the controllers do not revert the changes of each other")
+
+			Consistently(func() (string, error) {
+				foundRuntime := &mmv1alpha1.ServingRuntime{}
+				if err := cli.Get(ctx, key, foundRuntime); err != nil {
+					return "", err
+				}
+				return foundRuntime.ResourceVersion, nil
+			}, 3*time.Second, 100*time.Millisecond).Should(Equal(servingRuntime.ResourceVersion))
+		})
+	})
+})
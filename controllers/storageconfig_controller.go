@@ -29,16 +29,31 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"strconv"
+	"strings"
 )
 
 const (
 	storageSecretName = "storage-config"
+	// dataConnectionVerifySSLKey optionally disables ("false") the verification of the
+	// certificate of the S3 endpoint of a data connection
+	dataConnectionVerifySSLKey = "AWS_S3_VERIFY_SSL"
+	// dataConnectionCABundleKey optionally holds the PEM CA bundle of the S3 endpoint of a
+	// data connection, e.g. when it is signed by a private CA
+	dataConnectionCABundleKey = "AWS_CA_BUNDLE"
 )
 
 type StorageSecretReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
 	Log    logr.Logger
+	// ExcludeFromBackup labels the Storage Config Secrets to be excluded from the Velero
+	// backups, they are regenerated from the data connections
+	ExcludeFromBackup bool
+	// ConnectionTests enables the tests of the data connections requested by their
+	// annotation. The requests are sent from the controller pod to the endpoints set by the
+	// namespace users, so they are disabled by default
+	ConnectionTests bool
 }
 
 // newStorageSecret takes a list of data connection secrets and generates a single storage config secret
@@ -46,15 +61,21 @@ type StorageSecretReconciler struct {
 func newStorageSecret(dataConnectionSecretsList *corev1.SecretList) *corev1.Secret {
 	desiredSecret := &corev1.Secret{}
 	desiredSecret.Data = map[string][]byte{}
-	dataConnectionElement := map[string]string{}
 	storageByteData := map[string][]byte{}
 	for _, secret := range dataConnectionSecretsList.Items {
+		dataConnectionElement := map[string]string{}
 		dataConnectionElement["type"] = secret.Annotations["opendatahub.io/connection-type"]
 		dataConnectionElement["access_key_id"] = string(secret.Data["AWS_ACCESS_KEY_ID"])
 		dataConnectionElement["secret_access_key"] = string(secret.Data["AWS_SECRET_ACCESS_KEY"])
 		dataConnectionElement["endpoint_url"] = string(secret.Data["AWS_S3_ENDPOINT"])
 		dataConnectionElement["default_bucket"] = string(secret.Data["AWS_S3_BUCKET"])
 		dataConnectionElement["region"] = string(secret.Data["AWS_DEFAULT_REGION"])
+		if verifySSL, ok := secret.Data[dataConnectionVerifySSLKey]; ok {
+			dataConnectionElement["verify_ssl"] = verifySSLValue(string(verifySSL))
+		}
+		if caBundle, ok := secret.Data[dataConnectionCABundleKey]; ok && len(caBundle) > 0 {
+			dataConnectionElement["certificate"] = string(caBundle)
+		}
 		jsonBytes, _ := json.Marshal(dataConnectionElement)
 		storageByteData[secret.Name] = jsonBytes
 	}
@@ -62,6 +83,15 @@ func newStorageSecret(dataConnectionSecretsList *corev1.SecretList) *corev1.Secr
 	return desiredSecret
 }
 
+// verifySSLValue normalizes the verify_ssl value of a data connection to the "0" and "1"
+// of the storage config, the verification being enabled unless explicitly disabled
+func verifySSLValue(value string) string {
+	if verify, err := strconv.ParseBool(strings.TrimSpace(value)); err == nil && !verify {
+		return "0"
+	}
+	return "1"
+}
+
 // CompareStorageSecrets checks if two secrets are equal, if not return false
 func CompareStorageSecrets(s1 corev1.Secret, s2 corev1.Secret) bool {
 	return reflect.DeepEqual(s1.ObjectMeta.Labels, s2.ObjectMeta.Labels) && reflect.DeepEqual(s1.Data, s2.Data)
@@ -91,8 +121,8 @@ func (r *StorageSecretReconciler) reconcileSecret(secret *corev1.Secret,
 	desiredStorageSecret := newStorageSecret(dataConnectionSecretsList)
 	desiredStorageSecret.Name = storageSecretName
 	desiredStorageSecret.Namespace = secret.Namespace
-	desiredStorageSecret.Labels = map[string]string{}
-	desiredStorageSecret.Labels["opendatahub.io/managed"] = "true"
+	desiredStorageSecret.Labels = managedLabels(secret.Namespace, map[string]string{"opendatahub.io/managed": "true"})
+	excludeFromBackup(desiredStorageSecret, r.ExcludeFromBackup)
 
 	foundStorageSecret := &corev1.Secret{}
 	justCreated := false
@@ -117,6 +147,9 @@ func (r *StorageSecretReconciler) reconcileSecret(secret *corev1.Secret,
 		}
 	}
 
+	if !justCreated && managementPaused(foundStorageSecret, log) {
+		return nil
+	}
 	// Reconcile the Storage Config Secret if it has been manually modified
 	if !justCreated && !CompareStorageSecrets(*desiredStorageSecret, *foundStorageSecret) {
 		log.Info("Reconciling Storage Config Secret")
@@ -194,6 +227,15 @@ func (r *StorageSecretReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	if err != nil {
 		return ctrl.Result{}, err
 	}
+
+	// Test the data connection when requested by its annotation
+	if r.ConnectionTests && secret.Name != "" && secret.Name != storageSecretName {
+		err = r.reconcileConnectionTest(ctx, secret)
+		if err != nil {
+			log.Error(err, "Unable to report the result of the data connection test")
+			return ctrl.Result{}, err
+		}
+	}
 	return ctrl.Result{}, nil
 }
 
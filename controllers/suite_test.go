@@ -35,6 +35,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	routev1 "github.com/openshift/api/route/v1"
+	templatev1 "github.com/openshift/api/template/v1"
 	virtualservicev1 "istio.io/client-go/pkg/apis/networking/v1alpha3"
 	"k8s.io/client-go/kubernetes/scheme"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -109,6 +110,7 @@ var _ = BeforeSuite(func() {
 	utilruntime.Must(maistrav1.AddToScheme(scheme.Scheme))
 	utilruntime.Must(monitoringv1.AddToScheme(scheme.Scheme))
 	utilruntime.Must(mmv1alpha1.AddToScheme(scheme.Scheme))
+	utilruntime.Must(templatev1.AddToScheme(scheme.Scheme))
 
 	// +kubebuilder:scaffold:scheme
 
@@ -126,11 +128,17 @@ var _ = BeforeSuite(func() {
 
 	Expect(err).NotTo(HaveOccurred())
 
+	// The InferenceService controller uses the manager client to list the
+	// InferenceServices through the field indexes of the cache
 	err = (&OpenshiftInferenceServiceReconciler{
-		Client:       cli,
+		Client:       mgr.GetClient(),
 		Log:          ctrl.Log.WithName("controllers").WithName("inferenceservice-controller"),
 		Scheme:       scheme.Scheme,
 		MeshDisabled: false,
+		// The AcceleratorProfile CRD of the ODH dashboard is not installed
+		AcceleratorProfileDisabled: true,
+		MaintenanceResponderIP:     "10.0.0.1",
+		MaintenanceResponderPort:   8090,
 	}).SetupWithManager(mgr)
 	Expect(err).ToNot(HaveOccurred())
 
@@ -149,6 +157,16 @@ var _ = BeforeSuite(func() {
 	}).SetupWithManager(mgr)
 	Expect(err).ToNot(HaveOccurred())
 
+	// The ServingRuntime Templates are looked up in the namespace of the tests, the
+	// ServingRuntimes of a Template are listed through the field index of the cache
+	err = (&ServingRuntimeTemplateReconciler{
+		Client:      mgr.GetClient(),
+		Log:         ctrl.Log.WithName("controllers").WithName("servingruntimetemplate-controller"),
+		Scheme:      scheme.Scheme,
+		TemplatesNS: WorkingNamespace,
+	}).SetupWithManager(mgr)
+	Expect(err).ToNot(HaveOccurred())
+
 	// Start the manager
 	go func() {
 		defer GinkgoRecover()
@@ -174,6 +192,7 @@ var _ = AfterEach(func() {
 	Expect(cli.DeleteAllOf(context.TODO(), &mmv1alpha1.ServingRuntime{}, inNamespace)).ToNot(HaveOccurred())
 	Expect(cli.DeleteAllOf(context.TODO(), &monitoringv1.ServiceMonitor{}, inNamespace)).ToNot(HaveOccurred())
 	Expect(cli.DeleteAllOf(context.TODO(), &k8srbacv1.RoleBinding{}, inNamespace)).ToNot(HaveOccurred())
+	Expect(cli.DeleteAllOf(context.TODO(), &templatev1.Template{}, inNamespace)).ToNot(HaveOccurred())
 
 })
 
@@ -17,18 +17,29 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	inferenceservicev1 "github.com/kserve/modelmesh-serving/apis/serving/v1beta1"
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"net"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	uberzap "go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
@@ -37,8 +48,10 @@ import (
 	predictorv1 "github.com/kserve/modelmesh-serving/apis/serving/v1alpha1"
 	"github.com/opendatahub-io/odh-model-controller/controllers"
 	routev1 "github.com/openshift/api/route/v1"
+	templatev1 "github.com/openshift/api/template/v1"
 	corev1 "k8s.io/api/core/v1"
 	authv1 "k8s.io/api/rbac/v1"
+	maistrav1 "maistra.io/api/core/v1"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -56,15 +69,119 @@ func init() {
 	utilruntime.Must(routev1.AddToScheme(scheme))
 	utilruntime.Must(authv1.AddToScheme(scheme))
 	utilruntime.Must(monitoringv1.AddToScheme(scheme))
+	utilruntime.Must(maistrav1.AddToScheme(scheme))
+	utilruntime.Must(templatev1.AddToScheme(scheme))
 
 	// The following are related to Service Mesh, uncomment this and other
 	// similar blocks to use with Service Mesh
 	//utilruntime.Must(virtualservicev1.AddToScheme(scheme))
-	//utilruntime.Must(maistrav1.AddToScheme(scheme))
 
 	//+kubebuilder:scaffold:scheme
 }
 
+const (
+	inferenceServiceController = "inferenceservice"
+	storageSecretController    = "storagesecret"
+	monitoringController       = "monitoring"
+	namespaceController        = "namespace"
+	templateController         = "servingruntimetemplate"
+	pdbController              = "poddisruptionbudget"
+	modelDeploymentController  = "modeldeployment"
+)
+
+const (
+	// The credentials of the controller service account mounted in its pod
+	serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	serviceCAFile           = "/var/run/secrets/kubernetes.io/serviceaccount/service-ca.crt"
+)
+
+// parseControllers returns the set of controllers enabled by the comma separated list
+func parseControllers(list string) (map[string]bool, error) {
+	known := map[string]bool{
+		inferenceServiceController: true,
+		storageSecretController:    true,
+		monitoringController:       true,
+		namespaceController:        true,
+		templateController:         true,
+		pdbController:              true,
+		modelDeploymentController:  true,
+	}
+	enabled := map[string]bool{}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !known[name] {
+			return nil, fmt.Errorf("unknown controller %q", name)
+		}
+		enabled[name] = true
+	}
+	return enabled, nil
+}
+
+// parseRouteTLSPolicy returns the TLS policy of the routes configured by the flags
+func parseRouteTLSPolicy(termination string, secret string, hstsHeader string) (controllers.RouteTLSPolicy, error) {
+	policy := controllers.RouteTLSPolicy{HSTSHeader: hstsHeader}
+	var err error
+	if policy.Termination, err = controllers.ParseRouteTLSTermination(termination); err != nil {
+		return policy, err
+	}
+	// The private key of a shared secret would be copied to the routes of all the namespaces
+	if strings.Contains(secret, "/") {
+		return policy, fmt.Errorf("invalid route TLS secret %q, expected the name of a secret of the "+
+			"namespaces of the InferenceServices", secret)
+	}
+	policy.CertificateSecretName = secret
+	return policy, nil
+}
+
+// parseNamespacedName parses a <namespace>/<name> reference
+func parseNamespacedName(value string) (types.NamespacedName, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return types.NamespacedName{}, fmt.Errorf("expected <namespace>/<name>, got %q", value)
+	}
+	return types.NamespacedName{Namespace: parts[0], Name: parts[1]}, nil
+}
+
+// parseNamespacedNames parses a comma separated list of <namespace>/<name> references
+func parseNamespacedNames(list string) ([]types.NamespacedName, error) {
+	names := []types.NamespacedName{}
+	for _, item := range splitList(list) {
+		name, err := parseNamespacedName(item)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// parsePort returns the port of the bind address
+func parsePort(address string) (int32, error) {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseInt(port, 10, 32)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid port %q", port)
+	}
+	return int32(value), nil
+}
+
+// splitList returns the non empty items of the comma separated list
+func splitList(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func getEnvAsBool(name string, defaultValue bool) bool {
 	valStr := os.Getenv(name)
 	if val, err := strconv.ParseBool(valStr); err == nil {
@@ -73,20 +190,141 @@ func getEnvAsBool(name string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// dependencyAvailable returns true if the API of the dependency is served by the cluster,
+// otherwise it logs that the features relying on it are disabled
+func dependencyAvailable(checker *controllers.DependencyChecker, dependency controllers.Dependency) bool {
+	available, err := checker.Available(dependency)
+	if err != nil {
+		setupLog.Error(err, "unable to discover API", "groupVersion", dependency.GroupVersion, "kind", dependency.Kind)
+		os.Exit(1)
+	}
+	if !available {
+		setupLog.Info("API is not installed in the cluster, disabling the features relying on it",
+			"groupVersion", dependency.GroupVersion, "kind", dependency.Kind)
+	}
+	return available
+}
+
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var leaderElectionID string
+	var controllersList string
 	var monitoringNS string
+	var clusterMonitoringNS string
+	var monitoringServiceAccountsList string
+	var monitoringMeshCertsDir string
+	var templatesNS string
+	var recommendationsPrometheusURL string
+	var recommendationsInterval time.Duration
+	var recommendationsWindow time.Duration
+	var ingressClassName string
+	var sloWindow string
+	var propagatedLabels string
+	var dryRun bool
+	var excludeFromBackup bool
+	var injectProxy bool
+	var dataConnectionTests bool
+	var runtimePullSecretName string
+	var pruneStaleResources bool
+	var acceleratorProfileNS string
+	var routeTLSTermination string
+	var routeTLSSecret string
+	var routeHSTSHeader string
+	var logLevelsConfigMap string
+	var dependencyPollInterval time.Duration
 	var probeAddr string
+	var maintenanceAddr string
+	var maintenanceRetryAfter time.Duration
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&leaderElectionID, "leader-election-id", "odh-model-controller",
+		"The name of the resource used for the leader election. Replicas running different sets of "+
+			"controllers must use distinct IDs.")
+	flag.StringVar(&controllersList, "controllers",
+		strings.Join([]string{inferenceServiceController, storageSecretController, monitoringController,
+			pdbController}, ","),
+		"Comma separated list of the controllers to run, allowing to split the workload between deployments. "+
+			"Add "+namespaceController+" to provision the resources of the namespaces labeled for model serving "+
+			"when they are onboarded rather than with their first model. Add "+templateController+" to "+
+			"synchronize the ServingRuntimes with the ServingRuntime Templates they were created from. Add "+
+			modelDeploymentController+" to deploy the models requested by the deploy intents of the model registry.")
 	flag.StringVar(&monitoringNS, "monitoring-namespace", "",
 		"The Namespace where the monitoring stack's Prometheus resides.")
 	flag.StringVar(&monitoringNS, "apps-namespace", "",
 		"The Namespace where odh apps reside.")
+	flag.StringVar(&clusterMonitoringNS, "cluster-monitoring-namespace", controllers.OpenshiftMonitoringNS,
+		"The Namespace where the cluster monitoring stack resides.")
+	flag.StringVar(&monitoringServiceAccountsList, "monitoring-service-accounts", "",
+		"Comma separated list of the <namespace>/<name> of the Prometheus service accounts granted access to the "+
+			"model namespaces, e.g. openshift-user-workload-monitoring/prometheus-user-workload. By default the "+
+			controllers.MonitoringSA+" service account of the monitoring namespace.")
+	flag.StringVar(&monitoringMeshCertsDir, "monitoring-mesh-certs-dir", "",
+		"The directory where the Istio certificates are mounted in the Prometheus pods, e.g. /etc/istio-certs. "+
+			"When set, the metrics of the namespaces enrolled in the Service Mesh are scraped over its mTLS.")
+	flag.StringVar(&ingressClassName, "ingress-class", "",
+		"Expose the models with Ingresses of this class instead of Openshift Routes, "+
+			"to run the controller on non-Openshift clusters.")
+	flag.StringVar(&sloWindow, "slo-window", controllers.DefaultSLOWindow,
+		"The rate window of the SLO recording rules generated for the InferenceServices with SLO annotations.")
+	flag.StringVar(&propagatedLabels, "propagated-labels", "",
+		"Comma separated list of label keys copied from the InferenceServices, or their namespace, "+
+			"to the resources created by the controller.")
+	flag.StringVar(&routeTLSTermination, "route-tls-termination", "",
+		"TLS termination of the routes of the runtimes with auth enabled: reencrypt (default) or passthrough. "+
+			"The routes of the other runtimes use edge, the only termination supported by their backend.")
+	flag.StringVar(&routeTLSSecret, "route-tls-secret", "",
+		"The name of a secret with the tls.crt, tls.key and optional ca.crt served by the routes of its "+
+			"namespace. The routes of the namespaces without it use the default certificate of the router.")
+	flag.StringVar(&routeHSTSHeader, "route-hsts-header", "",
+		"Value of the Strict-Transport-Security header of the routes, e.g. max-age=31536000;includeSubDomains.")
+	flag.StringVar(&logLevelsConfigMap, "log-levels-configmap", "",
+		"The <namespace>/<name> of a ConfigMap setting the levels of the reconciler loggers at runtime, "+
+			"e.g. controllers.InferenceService: debug, or controllers.InferenceService.route: debug for a single "+
+			"sub-reconciler of the InferenceServices.")
+	flag.StringVar(&acceleratorProfileNS, "accelerator-profiles-namespace", "",
+		"The Namespace of the AcceleratorProfiles available to all the namespaces, usually the ODH dashboard one.")
+	flag.StringVar(&templatesNS, "serving-runtime-templates-namespace", "",
+		"The Namespace of the ServingRuntime Templates. When empty, the applications namespace of the "+
+			"DSCInitialization, or the monitoring namespace where the odh apps reside.")
+	flag.StringVar(&recommendationsPrometheusURL, "resource-recommendations-prometheus-url", "",
+		"The URL of the Prometheus API, e.g. https://thanos-querier.openshift-monitoring.svc:9091, queried for the "+
+			"resource usage of the ServingRuntimes to annotate them with recommended requests. Disabled when empty.")
+	flag.DurationVar(&recommendationsInterval, "resource-recommendations-interval", time.Hour,
+		"Interval at which the resource recommendations of the ServingRuntimes are refreshed.")
+	flag.DurationVar(&recommendationsWindow, "resource-recommendations-window", 7*24*time.Hour,
+		"The period of the resource usage the recommendations of the ServingRuntimes are based on.")
+	flag.BoolVar(&excludeFromBackup, "exclude-from-backup", false,
+		"Label the resources created by the controller, which are regenerated after a restore, "+
+			"to be excluded from the Velero backups.")
+	flag.BoolVar(&injectProxy, "inject-proxy", false,
+		"Inject the egress proxy configuration of the cluster Proxy, or of the controller environment, in the "+
+			"model server containers of the ServingRuntimes. The model puller injected by modelmesh-serving is not "+
+			"modified, the models are not downloaded through the proxy.")
+	flag.BoolVar(&dataConnectionTests, "enable-data-connection-tests", false,
+		"Test the data connections annotated with opendatahub.io/connection-test by sending a request to their S3 "+
+			"endpoint from the controller pod. The endpoints are set by the namespace users, only enable it when "+
+			"the controller network does not reach services they must not probe.")
+	flag.StringVar(&runtimePullSecretName, "runtime-pull-secret", "",
+		"The pull Secret, as <namespace>/<name>, of the private registry of the ServingRuntimes annotated with "+
+			"serving.opendatahub.io/use-pull-secret. It is replicated in the namespaces of their models. Disabled when empty.")
+	flag.BoolVar(&pruneStaleResources, "prune-stale-resources", true,
+		"Delete at startup the resources created by other versions of the controller whose kind is no longer "+
+			"managed by this version.")
+	flag.DurationVar(&dependencyPollInterval, "dependency-poll-interval", time.Minute,
+		"Interval at which the APIs are polled for the readiness check, the controllers are set up again once "+
+			"the ones missing at startup are installed. Set to 0 to disable the polling.")
+	flag.StringVar(&maintenanceAddr, "maintenance-bind-address", ":8090",
+		"The address the responder of the InferenceServices in external traffic maintenance binds to, "+
+			"reached by the routers at the POD_IP environment variable. Set to 0 to disable it.")
+	flag.DurationVar(&maintenanceRetryAfter, "maintenance-retry-after", 5*time.Minute,
+		"The Retry-After delay of the responses of the InferenceServices in external traffic maintenance.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Log the changes the controllers would make to the cluster, with the delta of the resources, without "+
+			"applying them nor recording them in the audit log.")
 
 	opts := zap.Options{
 		Development: true,
@@ -95,70 +333,429 @@ func main() {
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	// The reconcilers get their own loggers, whose levels can be tuned at runtime
+	defaultLogLevel := zapcore.InfoLevel
+	if opts.Development {
+		defaultLogLevel = zapcore.DebugLevel
+	}
+	if level, ok := opts.Level.(uberzap.AtomicLevel); ok {
+		defaultLogLevel = level.Level()
+	}
+	logLevels := controllers.NewLogLevels(defaultLogLevel, zap.UseFlagOptions(&opts))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
-		Port:                   9443,
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "odh-model-controller",
-	})
+	enabledControllers, err := parseControllers(controllersList)
 	if err != nil {
-		setupLog.Error(err, "unable to start manager")
+		setupLog.Error(err, "invalid list of controllers")
 		os.Exit(1)
 	}
 
-	//Setup InferenceService controller
-	if err = (&controllers.OpenshiftInferenceServiceReconciler{
-		Client:       mgr.GetClient(),
-		Log:          ctrl.Log.WithName("controllers").WithName("InferenceService"),
-		Scheme:       mgr.GetScheme(),
-		MeshDisabled: getEnvAsBool("MESH_DISABLED", false),
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "InferenceService")
+	routeTLSPolicy, err := parseRouteTLSPolicy(routeTLSTermination, routeTLSSecret, routeHSTSHeader)
+	if err != nil {
+		setupLog.Error(err, "invalid route TLS policy")
 		os.Exit(1)
 	}
 
-	if err = (&controllers.StorageSecretReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("StorageSecret"),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "StorageSecret")
+	var runtimePullSecret types.NamespacedName
+	if runtimePullSecretName != "" {
+		runtimePullSecret, err = parseNamespacedName(runtimePullSecretName)
+		if err != nil {
+			setupLog.Error(err, "invalid runtime pull Secret")
+			os.Exit(1)
+		}
+	}
+
+	monitoringServiceAccounts, err := parseNamespacedNames(monitoringServiceAccountsList)
+	if err != nil {
+		setupLog.Error(err, "invalid list of monitoring service accounts")
 		os.Exit(1)
 	}
 
-	if monitoringNS != "" {
-		setupLog.Info("Monitoring namespace provided, setting up monitoring controller.")
-		if err = (&controllers.MonitoringReconciler{
-			Client:       mgr.GetClient(),
-			Log:          ctrl.Log.WithName("controllers").WithName("MonitoringReconciler"),
-			Scheme:       mgr.GetScheme(),
-			MonitoringNS: monitoringNS,
-		}).SetupWithManager(mgr); err != nil {
-			setupLog.Error(err, "unable to create controller", "controller", "MonitoringReconciler")
+	// The routers reach the maintenance responder of the leader at the address of its pod
+	var maintenanceIP string
+	var maintenancePort int32
+	if maintenanceAddr != "0" {
+		maintenancePort, err = parsePort(maintenanceAddr)
+		if err != nil {
+			setupLog.Error(err, "invalid maintenance responder address")
 			os.Exit(1)
 		}
-	} else {
-		setupLog.Info("Monitoring namespace not provided, skipping setup of monitoring controller. To enable " +
-			"monitoring for ModelServing, please provide a monitoring namespace via the (--monitoring-namespace) flag.")
+		maintenanceIP = os.Getenv("POD_IP")
+		if maintenanceIP == "" {
+			setupLog.Info("POD_IP is not set, the InferenceServices in maintenance are answered by the router")
+		}
 	}
 
-	//+kubebuilder:scaffold:builder
+	cfg := ctrl.GetConfigOrDie()
+	// runManager sets up the controllers according to the APIs served by the cluster and
+	// runs them, it returns true when they have to be set up again
+	runManager := func(ctx context.Context) bool {
+		managerCtx, stop := context.WithCancel(ctx)
+		defer stop()
 
-	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
-		setupLog.Error(err, "unable to set up health check")
-		os.Exit(1)
-	}
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
-		setupLog.Error(err, "unable to set up ready check")
-		os.Exit(1)
+		mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+			Scheme:                 scheme,
+			MetricsBindAddress:     metricsAddr,
+			Port:                   9443,
+			HealthProbeBindAddress: probeAddr,
+			LeaderElection:         enableLeaderElection,
+			LeaderElectionID:       leaderElectionID,
+		})
+		if err != nil {
+			setupLog.Error(err, "unable to start manager")
+			os.Exit(1)
+		}
+
+		// The reconcilers share the client, wrapped to preview their changes in dry-run mode
+		reconcilerClient := mgr.GetClient()
+		if dryRun {
+			setupLog.Info("Dry-run mode enabled, the changes of the controllers are logged but not applied")
+			reconcilerClient = controllers.NewDryRunClient(reconcilerClient, ctrl.Log.WithName("dry-run"))
+		}
+
+		// The readiness check reuses the dependencies discovered by the watcher, or discovers
+		// them again every minute when the polling is disabled
+		dependencyCheckMaxAge := dependencyPollInterval
+		if dependencyCheckMaxAge <= 0 {
+			dependencyCheckMaxAge = time.Minute
+		}
+		dependencyChecker := &controllers.DependencyChecker{
+			Discovery: discovery.NewDiscoveryClientForConfigOrDie(cfg),
+			Log:       ctrl.Log.WithName("dependencies"),
+			MaxAge:    dependencyCheckMaxAge,
+		}
+		// The dependencies missing now are watched to set up the controllers again once installed
+		missingDependencies := []controllers.Dependency{}
+		available := func(dependency controllers.Dependency) bool {
+			if !dependencyAvailable(dependencyChecker, dependency) {
+				missingDependencies = append(missingDependencies, dependency)
+				return false
+			}
+			return true
+		}
+		servingAvailable := available(controllers.InferenceServiceDependency) &&
+			available(controllers.ServingRuntimeDependency)
+
+		// The DSCInitialization of the ODH operator configures the settings whose flags are
+		// left empty. The flags are kept untouched, as the controllers are set up again when
+		// the DSCInitialization changes
+		var platformConfig *controllers.PlatformConfig
+		platformAvailable := available(controllers.DSCInitializationDependency)
+		if platformAvailable {
+			platformConfig, err = controllers.ReadPlatformConfig(ctx, mgr.GetAPIReader())
+			if err != nil {
+				setupLog.Error(err, "unable to read the DSCInitialization")
+				os.Exit(1)
+			}
+		}
+		monitoringNS, templatesNS, acceleratorProfileNS := monitoringNS, templatesNS, acceleratorProfileNS
+		meshRemoved, meshControlPlane := false, types.NamespacedName{}
+		if platformConfig != nil {
+			setupLog.Info("Using the configuration of the DSCInitialization", "config", platformConfig)
+			if monitoringNS == "" {
+				monitoringNS = platformConfig.MonitoringNS
+			}
+			if templatesNS == "" {
+				templatesNS = platformConfig.ApplicationsNS
+			}
+			if acceleratorProfileNS == "" {
+				acceleratorProfileNS = platformConfig.ApplicationsNS
+			}
+			meshRemoved = platformConfig.MeshRemoved
+			meshControlPlane = platformConfig.MeshControlPlane
+		}
+
+		// The model namespaces are enrolled in the Service Mesh unless the ODH operator removed
+		// it, set MESH_DISABLED to override it
+		meshDisabled := getEnvAsBool("MESH_DISABLED", meshRemoved) ||
+			!available(controllers.ServiceMeshMemberDependency)
+		// The proxy of the cluster is read at startup, the controllers are set up again when
+		// the cluster Proxy changes
+		var clusterProxyEnv, proxyEnv []corev1.EnvVar
+		if injectProxy {
+			if available(controllers.ProxyDependency) {
+				clusterProxyEnv, err = controllers.ReadProxyEnv(ctx, mgr.GetAPIReader())
+				if err != nil {
+					setupLog.Error(err, "unable to read the cluster Proxy")
+					os.Exit(1)
+				}
+			}
+			proxyEnv = clusterProxyEnv
+			if len(proxyEnv) == 0 {
+				proxyEnv = controllers.ProxyEnvFromEnvironment()
+			}
+		}
+		// The onboarded namespaces get their resources from the namespace controller instead
+		// of the controllers of the models
+		namespaceOnboarding := enabledControllers[namespaceController]
+
+		//Setup InferenceService controller
+		if servingAvailable && enabledControllers[inferenceServiceController] {
+			if err = (&controllers.OpenshiftInferenceServiceReconciler{
+				Client:                     reconcilerClient,
+				Log:                        logLevels.Logger("controllers.InferenceService"),
+				Scheme:                     mgr.GetScheme(),
+				MeshDisabled:               meshDisabled || namespaceOnboarding,
+				MeshControlPlane:           meshControlPlane,
+				RouteDisabled:              ingressClassName != "" || !available(controllers.RouteDependency),
+				IngressClassName:           ingressClassName,
+				RouteTLSPolicy:             routeTLSPolicy,
+				PrometheusRuleDisabled:     !available(controllers.PrometheusRuleDependency),
+				SLOWindow:                  sloWindow,
+				PropagatedLabels:           splitList(propagatedLabels),
+				AcceleratorProfileDisabled: !available(controllers.AcceleratorProfileDependency),
+				AcceleratorProfileNS:       acceleratorProfileNS,
+				ExcludeFromBackup:          excludeFromBackup,
+				RuntimePullSecret:          runtimePullSecret,
+				InjectProxy:                injectProxy,
+				ProxyEnv:                   proxyEnv,
+				MaintenanceResponderIP:     maintenanceIP,
+				MaintenanceResponderPort:   maintenancePort,
+				// The sub-reconcilers log with their own logger, e.g. controllers.InferenceService.route
+				SubReconcilerLogger: func(name string) logr.Logger {
+					return logLevels.Logger("controllers.InferenceService." + name)
+				},
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "InferenceService")
+				os.Exit(1)
+			}
+		} else if !servingAvailable {
+			setupLog.Info("KServe ModelMesh APIs not installed, skipping setup of InferenceService controller.")
+		}
+
+		if enabledControllers[storageSecretController] {
+			if err = (&controllers.StorageSecretReconciler{
+				Client:            reconcilerClient,
+				Log:               logLevels.Logger("controllers.StorageSecret"),
+				Scheme:            mgr.GetScheme(),
+				ExcludeFromBackup: excludeFromBackup,
+				ConnectionTests:   dataConnectionTests,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "StorageSecret")
+				os.Exit(1)
+			}
+		}
+
+		if !enabledControllers[monitoringController] {
+			setupLog.Info("Monitoring controller not enabled, skipping its setup.")
+		} else if monitoringNS != "" && servingAvailable {
+			setupLog.Info("Monitoring namespace provided, setting up monitoring controller.")
+			meshCertsDir := monitoringMeshCertsDir
+			if meshCertsDir != "" && !available(controllers.ServiceMeshMemberDependency) {
+				meshCertsDir = ""
+			}
+			if err = (&controllers.MonitoringReconciler{
+				Client:                    reconcilerClient,
+				Log:                       logLevels.Logger("controllers.MonitoringReconciler"),
+				Scheme:                    mgr.GetScheme(),
+				MonitoringNS:              monitoringNS,
+				ClusterMonitoringNS:       clusterMonitoringNS,
+				ServiceMonitorDisabled:    !available(controllers.ServiceMonitorDependency),
+				MeshCertsDir:              meshCertsDir,
+				NamespaceOnboarding:       namespaceOnboarding,
+				MonitoringServiceAccounts: monitoringServiceAccounts,
+				ExcludeFromBackup:         excludeFromBackup,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "MonitoringReconciler")
+				os.Exit(1)
+			}
+		} else if monitoringNS == "" {
+			setupLog.Info("Monitoring namespace not provided, skipping setup of monitoring controller. To enable " +
+				"monitoring for ModelServing, please provide a monitoring namespace via the (--monitoring-namespace) flag.")
+		}
+
+		if namespaceOnboarding {
+			if err = (&controllers.NamespaceReconciler{
+				Client:                    reconcilerClient,
+				Log:                       logLevels.Logger("controllers.Namespace"),
+				Scheme:                    mgr.GetScheme(),
+				MeshDisabled:              meshDisabled,
+				MeshControlPlane:          meshControlPlane,
+				MonitoringNS:              monitoringNS,
+				MonitoringServiceAccounts: monitoringServiceAccounts,
+				ExcludeFromBackup:         excludeFromBackup,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "Namespace")
+				os.Exit(1)
+			}
+		}
+
+		if enabledControllers[templateController] && servingAvailable && available(controllers.TemplateDependency) {
+			if templatesNS == "" {
+				templatesNS = monitoringNS
+			}
+			if templatesNS == "" {
+				setupLog.Error(fmt.Errorf("no namespace of the ServingRuntime Templates"),
+					"please provide it via the (--serving-runtime-templates-namespace) flag")
+				os.Exit(1)
+			}
+			if err = (&controllers.ServingRuntimeTemplateReconciler{
+				Client:      reconcilerClient,
+				Log:         logLevels.Logger("controllers.ServingRuntimeTemplate"),
+				Scheme:      mgr.GetScheme(),
+				TemplatesNS: templatesNS,
+				ProxyEnv:    proxyEnv,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "ServingRuntimeTemplate")
+				os.Exit(1)
+			}
+		}
+
+		if enabledControllers[modelDeploymentController] && servingAvailable {
+			// The ServingRuntimes can only be created from the Templates when they are served
+			deployTemplatesNS := ""
+			if available(controllers.TemplateDependency) {
+				deployTemplatesNS = templatesNS
+				if deployTemplatesNS == "" {
+					deployTemplatesNS = monitoringNS
+				}
+			}
+			if err = (&controllers.ModelDeploymentReconciler{
+				Client:      reconcilerClient,
+				Log:         logLevels.Logger("controllers.ModelDeployment"),
+				Scheme:      mgr.GetScheme(),
+				TemplatesNS: deployTemplatesNS,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "ModelDeployment")
+				os.Exit(1)
+			}
+		}
+
+		if enabledControllers[pdbController] && servingAvailable {
+			if err = (&controllers.PodDisruptionBudgetReconciler{
+				Client:            reconcilerClient,
+				Log:               logLevels.Logger("controllers.PodDisruptionBudget"),
+				Scheme:            mgr.GetScheme(),
+				ExcludeFromBackup: excludeFromBackup,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "PodDisruptionBudget")
+				os.Exit(1)
+			}
+		}
+
+		if recommendationsPrometheusURL != "" && servingAvailable {
+			recommender := &controllers.ResourceRecommender{
+				Client:          reconcilerClient,
+				Log:             logLevels.Logger("controllers.ResourceRecommender"),
+				PrometheusURL:   recommendationsPrometheusURL,
+				BearerTokenFile: serviceAccountTokenFile,
+				Interval:        recommendationsInterval,
+				Window:          recommendationsWindow,
+			}
+			// The Openshift service CA signs the certificates of the cluster monitoring stack
+			if _, err := os.Stat(serviceCAFile); err == nil {
+				recommender.CAFile = serviceCAFile
+			}
+			if err := mgr.Add(recommender); err != nil {
+				setupLog.Error(err, "unable to set up resource recommender")
+				os.Exit(1)
+			}
+		}
+
+		if maintenanceAddr != "0" {
+			if err := mgr.Add(&controllers.MaintenanceResponder{
+				BindAddress: maintenanceAddr,
+				RetryAfter:  maintenanceRetryAfter,
+				Log:         ctrl.Log.WithName("maintenance"),
+			}); err != nil {
+				setupLog.Error(err, "unable to set up maintenance responder")
+				os.Exit(1)
+			}
+		}
+
+		if pruneStaleResources {
+			if err := mgr.Add(&controllers.Pruner{
+				Client:    reconcilerClient,
+				Reader:    mgr.GetAPIReader(),
+				Discovery: dependencyChecker.Discovery,
+				Log:       ctrl.Log.WithName("controllers").WithName("Pruner"),
+			}); err != nil {
+				setupLog.Error(err, "unable to set up pruner")
+				os.Exit(1)
+			}
+		}
+
+		if logLevelsConfigMap != "" {
+			configMap, err := parseNamespacedName(logLevelsConfigMap)
+			if err != nil {
+				setupLog.Error(err, "invalid log levels ConfigMap")
+				os.Exit(1)
+			}
+			if err = (&controllers.LogLevelReconciler{
+				Client:    mgr.GetClient(),
+				Log:       ctrl.Log.WithName("controllers").WithName("LogLevels"),
+				LogLevels: logLevels,
+				ConfigMap: configMap,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "LogLevels")
+				os.Exit(1)
+			}
+		}
+
+		//+kubebuilder:scaffold:builder
+
+		if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+			setupLog.Error(err, "unable to set up health check")
+			os.Exit(1)
+		}
+		if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+			setupLog.Error(err, "unable to set up ready check")
+			os.Exit(1)
+		}
+		if err := mgr.AddReadyzCheck("dependencies", dependencyChecker.Check); err != nil {
+			setupLog.Error(err, "unable to set up dependencies check")
+			os.Exit(1)
+		}
+
+		platformConfigReconciler := &controllers.PlatformConfigReconciler{
+			Client: mgr.GetClient(),
+			Log:    ctrl.Log.WithName("controllers").WithName("PlatformConfig"),
+			Config: platformConfig,
+			Stop:   stop,
+		}
+		if platformAvailable {
+			if err = platformConfigReconciler.SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "PlatformConfig")
+				os.Exit(1)
+			}
+		}
+
+		proxyConfigReconciler := &controllers.ProxyConfigReconciler{
+			Client: mgr.GetClient(),
+			Log:    ctrl.Log.WithName("controllers").WithName("ProxyConfig"),
+			Env:    clusterProxyEnv,
+			Stop:   stop,
+		}
+		if injectProxy && available(controllers.ProxyDependency) {
+			if err = proxyConfigReconciler.SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "ProxyConfig")
+				os.Exit(1)
+			}
+		}
+
+		dependencyWatcher := &controllers.DependencyWatcher{
+			Checker:  dependencyChecker,
+			Missing:  missingDependencies,
+			Interval: dependencyPollInterval,
+			Stop:     stop,
+		}
+		if dependencyPollInterval > 0 {
+			if err := mgr.Add(dependencyWatcher); err != nil {
+				setupLog.Error(err, "unable to set up dependency watcher")
+				os.Exit(1)
+			}
+		}
+
+		setupLog.Info("starting manager", "version", controllers.ControllerVersion)
+		if err := mgr.Start(managerCtx); err != nil {
+			setupLog.Error(err, "problem running manager")
+			os.Exit(1)
+		}
+		return dependencyWatcher.Installed() || platformConfigReconciler.Changed() ||
+			proxyConfigReconciler.Changed()
 	}
 
-	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
-		setupLog.Error(err, "problem running manager")
-		os.Exit(1)
+	ctx := ctrl.SetupSignalHandler()
+	for runManager(ctx) {
+		setupLog.Info("restarting manager")
 	}
 }